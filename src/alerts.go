@@ -0,0 +1,428 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fields an alert rule can compare against
+const (
+	AlertFieldLend   = "lend"
+	AlertFieldBorrow = "borrow"
+)
+
+var alertOperators = []string{">=", "<=", ">", "<"}
+
+// dropRuleKey identifies drop notifications in persisted alert state
+const dropRuleKey = "drop"
+
+// AlertRule is a user-defined notification condition such as
+// "USDT lend > 25 source=Neptune". Empty Token, Source and Category match
+// every rate. Rules with ID 0 are implicit rules derived from thresholds.
+type AlertRule struct {
+	ID       int64
+	ChatID   int64
+	Token    string
+	Field    string
+	Operator string
+	Value    float64
+	Source   string
+	Category string
+}
+
+// AlertMatch is a rate that triggered a rule
+type AlertMatch struct {
+	Rule AlertRule
+	Rate Rate
+}
+
+// parseAlertRule parses rule arguments of the form
+// [token] <lend|borrow> <operator> <percent> [source=name] [category=CEX|DEX]
+func parseAlertRule(args []string) (AlertRule, error) {
+	var rule AlertRule
+	if len(args) == 0 {
+		return rule, fmt.Errorf("empty rule")
+	}
+
+	if normalizeAlertField(args[0]) == "" {
+		rule.Token = strings.ToUpper(args[0])
+		args = args[1:]
+	}
+
+	if len(args) < 3 {
+		return rule, fmt.Errorf("expected <lend|borrow> <operator> <percent>")
+	}
+
+	rule.Field = normalizeAlertField(args[0])
+	if rule.Field == "" {
+		return rule, fmt.Errorf("unknown field %q, expected lend or borrow", args[0])
+	}
+
+	rule.Operator = args[1]
+	if !isAlertOperator(rule.Operator) {
+		return rule, fmt.Errorf("unknown operator %q, expected one of %s",
+			args[1], strings.Join(alertOperators, " "))
+	}
+
+	value, err := parsePercent(args[2])
+	if err != nil {
+		return rule, err
+	}
+	rule.Value = value
+
+	for _, filter := range args[3:] {
+		key, value, ok := strings.Cut(filter, "=")
+		if !ok || value == "" {
+			return rule, fmt.Errorf("invalid filter %q, expected key=value", filter)
+		}
+		switch strings.ToLower(key) {
+		case "source":
+			rule.Source = value
+		case "category":
+			rule.Category = strings.ToUpper(value)
+			if rule.Category != "CEX" && rule.Category != "DEX" {
+				return rule, fmt.Errorf("unknown category %q, expected CEX or DEX", value)
+			}
+		default:
+			return rule, fmt.Errorf("unknown filter %q, expected source or category", key)
+		}
+	}
+
+	return rule, nil
+}
+
+func normalizeAlertField(s string) string {
+	switch strings.ToLower(s) {
+	case "lend", "lending":
+		return AlertFieldLend
+	case "borrow", "borrowing":
+		return AlertFieldBorrow
+	default:
+		return ""
+	}
+}
+
+func isAlertOperator(op string) bool {
+	for _, candidate := range alertOperators {
+		if op == candidate {
+			return true
+		}
+	}
+	return false
+}
+
+// String renders the rule in the same syntax accepted by parseAlertRule
+func (r AlertRule) String() string {
+	parts := []string{}
+	if r.Token != "" {
+		parts = append(parts, r.Token)
+	}
+	parts = append(parts, r.Field, r.Operator, strconv.FormatFloat(r.Value, 'f', -1, 64)+"%")
+	if r.Source != "" {
+		parts = append(parts, "source="+r.Source)
+	}
+	if r.Category != "" {
+		parts = append(parts, "category="+r.Category)
+	}
+	return strings.Join(parts, " ")
+}
+
+// value returns the rate field the rule compares against
+func (r AlertRule) value(rate Rate) float64 {
+	if r.Field == AlertFieldBorrow {
+		return rate.BorrowRate
+	}
+	return rate.LendingRate
+}
+
+// appliesTo reports whether the rule's token, source and category filters
+// select the rate. Borrow rules skip venues without a borrow market, which
+// report a zero borrow rate.
+func (r AlertRule) appliesTo(rate Rate) bool {
+	if r.Token != "" && r.Token != rate.Token {
+		return false
+	}
+	if r.Field == AlertFieldBorrow && rate.BorrowRate <= 0 {
+		return false
+	}
+	if r.Source != "" && !strings.EqualFold(r.Source, rate.Source) {
+		return false
+	}
+	if r.Category != "" && r.Category != rate.Category {
+		return false
+	}
+	return true
+}
+
+// Matches reports whether the rate satisfies the rule's condition
+func (r AlertRule) Matches(rate Rate) bool {
+	if !r.appliesTo(rate) {
+		return false
+	}
+
+	value := r.value(rate)
+	switch r.Operator {
+	case ">":
+		return value > r.Value
+	case ">=":
+		return value >= r.Value
+	case "<":
+		return value < r.Value
+	case "<=":
+		return value <= r.Value
+	default:
+		return false
+	}
+}
+
+// changedSignificantly reports whether the field compared by the rule moved
+// enough since the previous rate to warrant another notification
+func (r AlertRule) changedSignificantly(oldRate, newRate Rate) bool {
+	if r.Field == AlertFieldBorrow {
+		return hasSignificantBorrowChange(oldRate, newRate)
+	}
+	return hasSignificantChange(oldRate, newRate)
+}
+
+// thresholdRules turns lending thresholds into implicit "lend >= x" rules
+func thresholdRules(thresholds map[string]float64) []AlertRule {
+	var rules []AlertRule
+	for token, threshold := range thresholds {
+		rules = append(rules, AlertRule{
+			Token:    token,
+			Field:    AlertFieldLend,
+			Operator: ">=",
+			Value:    threshold,
+		})
+	}
+	return rules
+}
+
+// borrowThresholdRules turns borrow bounds into implicit "borrow >= ceiling"
+// and "borrow <= floor" rules
+func borrowThresholdRules(thresholds map[string]BorrowThreshold) []AlertRule {
+	var rules []AlertRule
+	for token, threshold := range thresholds {
+		if threshold.Ceiling > 0 {
+			rules = append(rules, AlertRule{
+				Token:    token,
+				Field:    AlertFieldBorrow,
+				Operator: ">=",
+				Value:    threshold.Ceiling,
+			})
+		}
+		if threshold.Floor > 0 {
+			rules = append(rules, AlertRule{
+				Token:    token,
+				Field:    AlertFieldBorrow,
+				Operator: "<=",
+				Value:    threshold.Floor,
+			})
+		}
+	}
+	return rules
+}
+
+// key identifies the rule in persisted alert state. Threshold rules leave
+// out their value so changing a threshold keeps the rule's state.
+func (r AlertRule) key() string {
+	if r.ID != 0 {
+		return fmt.Sprintf("rule:%d", r.ID)
+	}
+	return fmt.Sprintf("threshold:%s %s %s", r.Token, r.Field, r.Operator)
+}
+
+// clearedBy reports whether a value has moved back past the rule's limit by
+// at least the hysteresis band, re-arming the rule
+func (r AlertRule) clearedBy(value, band float64) bool {
+	switch r.Operator {
+	case ">", ">=":
+		return value < r.Value-band
+	default:
+		return value > r.Value+band
+	}
+}
+
+// AlertState tracks whether a chat's rule currently holds for a token at a
+// source and when it last notified
+type AlertState struct {
+	ChatID      int64
+	Token       string
+	Source      string
+	RuleKey     string
+	Active      bool
+	LastAlertAt time.Time
+}
+
+// alertTracker holds a chat's alert states during one evaluation pass and
+// records which of them changed
+type alertTracker struct {
+	chatID  int64
+	now     time.Time
+	states  map[string]*AlertState
+	changed map[string]bool
+}
+
+func newAlertTracker(chatID int64, states []AlertState, now time.Time) *alertTracker {
+	tracker := &alertTracker{
+		chatID:  chatID,
+		now:     now,
+		states:  make(map[string]*AlertState, len(states)),
+		changed: make(map[string]bool),
+	}
+	for i := range states {
+		state := states[i]
+		tracker.states[alertStateKey(state.Token, state.Source, state.RuleKey)] = &state
+	}
+	return tracker
+}
+
+func alertStateKey(token, source, ruleKey string) string {
+	return token + "|" + source + "|" + ruleKey
+}
+
+// state returns the state for a rate and rule, creating an inactive one
+func (t *alertTracker) state(rate Rate, ruleKey string) *AlertState {
+	key := alertStateKey(rate.Token, rate.Source, ruleKey)
+	state, exists := t.states[key]
+	if !exists {
+		state = &AlertState{ChatID: t.chatID, Token: rate.Token, Source: rate.Source, RuleKey: ruleKey}
+		t.states[key] = state
+	}
+	return state
+}
+
+// coolingDown reports whether the state alerted within the cooldown window
+func (t *alertTracker) coolingDown(state *AlertState) bool {
+	return !state.LastAlertAt.IsZero() && t.now.Sub(state.LastAlertAt) < currentConfig().AlertCooldown
+}
+
+func (t *alertTracker) setActive(state *AlertState, active bool) {
+	state.Active = active
+	t.changed[alertStateKey(state.Token, state.Source, state.RuleKey)] = true
+}
+
+func (t *alertTracker) alerted(state *AlertState) {
+	state.LastAlertAt = t.now
+	t.setActive(state, true)
+}
+
+// changedStates returns the states that need to be persisted
+func (t *alertTracker) changedStates() []AlertState {
+	var states []AlertState
+	for key := range t.changed {
+		states = append(states, *t.states[key])
+	}
+	return states
+}
+
+// evaluateAlertRules checks rates against rules and returns the matches that
+// should be notified. A rule fires when its condition starts to hold. While
+// it keeps holding it fires again only once its cooldown has expired and the
+// rate moved significantly since the previous run. It re-arms, without
+// waiting for the cooldown, after the rate moves back past the rule by the
+// hysteresis band.
+func evaluateAlertRules(rates []Rate, previous map[string]map[string]Rate, settings chatSettings, tracker *alertTracker) []AlertMatch {
+	var matches []AlertMatch
+	for _, rate := range rates {
+		if rate.Category == "CEX" && !settings.showCEX {
+			continue
+		}
+		if !settings.isWatched(rate.Token) {
+			continue
+		}
+
+		prevRate, hasPrevious := previous[rate.Token][rate.Source]
+		for _, rule := range settings.rules {
+			if !rule.appliesTo(rate) {
+				continue
+			}
+
+			state := tracker.state(rate, rule.key())
+			if !rule.Matches(rate) {
+				if state.Active && rule.clearedBy(rule.value(rate), currentConfig().AlertHysteresis) {
+					tracker.setActive(state, false)
+				}
+				continue
+			}
+
+			if state.Active && (tracker.coolingDown(state) || !hasPrevious || !rule.changedSignificantly(prevRate, rate)) {
+				continue
+			}
+
+			tracker.alerted(state)
+			matches = append(matches, AlertMatch{Rule: rule, Rate: rate})
+		}
+	}
+	return matches
+}
+
+// RateDrop describes a lending rate that fell below a chat's threshold or
+// dropped sharply since the previous run
+type RateDrop struct {
+	Rate         Rate
+	Previous     Rate
+	Threshold    float64
+	CrossedBelow bool
+	DropPercent  float64
+}
+
+// detectRateDrops compares rates to the previous run and reports tokens with a
+// lending threshold whose rate was at or above it and has now crossed below
+// it or dropped by at least rateDropThreshold percent. Drops for the same
+// token and source are not repeated within the alert cooldown.
+func detectRateDrops(rates []Rate, previous map[string]map[string]Rate, settings chatSettings, tracker *alertTracker) []RateDrop {
+	dropThreshold := currentConfig().RateDropThreshold
+	var drops []RateDrop
+	for _, rate := range rates {
+		if rate.Category == "CEX" && !settings.showCEX {
+			continue
+		}
+		if !settings.isWatched(rate.Token) {
+			continue
+		}
+		threshold, exists := settings.thresholds[rate.Token]
+		if !exists {
+			continue
+		}
+		prevRate, hasPrevious := previous[rate.Token][rate.Source]
+		if !hasPrevious || prevRate.LendingRate < threshold || prevRate.LendingRate <= 0 {
+			continue
+		}
+
+		drop := RateDrop{
+			Rate:         rate,
+			Previous:     prevRate,
+			Threshold:    threshold,
+			CrossedBelow: rate.LendingRate < threshold,
+			DropPercent:  (prevRate.LendingRate - rate.LendingRate) / prevRate.LendingRate * 100,
+		}
+		if !drop.CrossedBelow && drop.DropPercent < dropThreshold {
+			continue
+		}
+
+		state := tracker.state(rate, dropRuleKey)
+		if tracker.coolingDown(state) {
+			continue
+		}
+		tracker.alerted(state)
+		drops = append(drops, drop)
+	}
+	return drops
+}
+
+// formatAlertRules lists a chat's custom alert rules
+func formatAlertRules(rules []AlertRule) string {
+	if len(rules) == 0 {
+		return "You have no custom alerts.\nExample: /alert add USDT lend > 25 source=Neptune"
+	}
+
+	var message strings.Builder
+	message.WriteString("*Your Alerts*\n")
+	for _, rule := range rules {
+		message.WriteString(fmt.Sprintf("`#%-3d %s`\n", rule.ID, rule))
+	}
+	return message.String()
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseAlertRule(t *testing.T) {
+	tests := []struct {
+		input       string
+		want        AlertRule
+		expectError bool
+	}{
+		{
+			input: "USDT lend > 25 source=Neptune",
+			want:  AlertRule{Token: "USDT", Field: AlertFieldLend, Operator: ">", Value: 25, Source: "Neptune"},
+		},
+		{
+			input: "borrow < 8 category=dex",
+			want:  AlertRule{Field: AlertFieldBorrow, Operator: "<", Value: 8, Category: "DEX"},
+		},
+		{
+			input: "usdc lending >= 12.5%",
+			want:  AlertRule{Token: "USDC", Field: AlertFieldLend, Operator: ">=", Value: 12.5},
+		},
+		{input: "", expectError: true},
+		{input: "USDT lend >", expectError: true},
+		{input: "USDT supply > 25", expectError: true},
+		{input: "USDT lend == 25", expectError: true},
+		{input: "USDT lend > abc", expectError: true},
+		{input: "USDT lend > NaN", expectError: true},
+		{input: "USDT lend < inf", expectError: true},
+		{input: "USDT lend > 25 venue=OKX", expectError: true},
+		{input: "USDT lend > 25 category=AMM", expectError: true},
+		{input: "USDT lend > 25 source", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseAlertRule(strings.Fields(tt.input))
+			if (err != nil) != tt.expectError {
+				t.Fatalf("parseAlertRule(%q) error = %v, expectError %v", tt.input, err, tt.expectError)
+			}
+			if !tt.expectError && got != tt.want {
+				t.Errorf("parseAlertRule(%q) = %+v, want %+v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAlertRule_String(t *testing.T) {
+	input := "USDT lend >= 12.5% source=Neptune category=DEX"
+	rule, err := parseAlertRule(strings.Fields(input))
+	if err != nil {
+		t.Fatalf("parseAlertRule() error = %v", err)
+	}
+
+	roundTrip, err := parseAlertRule(strings.Fields(rule.String()))
+	if err != nil {
+		t.Fatalf("parseAlertRule(String()) error = %v", err)
+	}
+	if roundTrip != rule {
+		t.Errorf("String() did not round-trip: %q -> %+v", rule, roundTrip)
+	}
+}
+
+func TestAlertRule_Key(t *testing.T) {
+	old := AlertRule{Token: "USDT", Field: AlertFieldLend, Operator: ">=", Value: 30}
+	changed := old
+	changed.Value = 25
+	if old.key() != changed.key() {
+		t.Errorf("key() changed with the threshold value: %q != %q", old.key(), changed.key())
+	}
+
+	floor := AlertRule{Token: "USDT", Field: AlertFieldBorrow, Operator: "<=", Value: 5}
+	ceiling := AlertRule{Token: "USDT", Field: AlertFieldBorrow, Operator: ">=", Value: 5}
+	if floor.key() == ceiling.key() || floor.key() == old.key() {
+		t.Errorf("key() collides across fields or operators: %q, %q, %q", floor.key(), ceiling.key(), old.key())
+	}
+	if got := (AlertRule{ID: 7, Token: "USDT"}).key(); got != "rule:7" {
+		t.Errorf("key() for custom rule = %q, want rule:7", got)
+	}
+}
+
+func TestAlertRule_Matches(t *testing.T) {
+	neptune := Rate{Source: "Neptune", Token: "USDT", Category: "DEX", LendingRate: 30, BorrowRate: 7}
+	okx := Rate{Source: "OKX", Token: "USDT", Category: "CEX", LendingRate: 30, BorrowRate: 7}
+
+	tests := []struct {
+		rule string
+		rate Rate
+		want bool
+	}{
+		{"USDT lend > 25", neptune, true},
+		{"USDT lend > 30", neptune, false},
+		{"USDT lend >= 30", neptune, true},
+		{"USDC lend > 25", neptune, false},
+		{"lend > 25 source=neptune", neptune, true},
+		{"lend > 25 source=Neptune", okx, false},
+		{"borrow < 8 category=DEX", neptune, true},
+		{"borrow < 8 category=DEX", okx, false},
+		{"borrow <= 7", okx, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule+"/"+tt.rate.Source, func(t *testing.T) {
+			rule, err := parseAlertRule(strings.Fields(tt.rule))
+			if err != nil {
+				t.Fatalf("parseAlertRule() error = %v", err)
+			}
+			if got := rule.Matches(tt.rate); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateAlertRules(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	thresholdRule := thresholdRules(map[string]float64{"USDT": 30})[0]
+	settings := chatSettings{
+		showCEX: true,
+		rules: []AlertRule{
+			{ID: 1, Field: AlertFieldBorrow, Operator: "<", Value: 8, Category: "DEX"},
+			thresholdRule,
+		},
+	}
+	okx := func(lending float64) Rate {
+		return Rate{Source: "OKX", Token: "USDT", Category: "CEX", LendingRate: lending}
+	}
+	activeState := func(lastAlert time.Time) []AlertState {
+		return []AlertState{{Token: "USDT", Source: "OKX", RuleKey: thresholdRule.key(), Active: true, LastAlertAt: lastAlert}}
+	}
+
+	tests := []struct {
+		name       string
+		states     []AlertState
+		previous   Rate
+		rate       Rate
+		settings   chatSettings
+		wantRule   int64 // -1 for no match
+		wantActive bool
+	}{
+		{
+			name:       "first time above threshold",
+			rate:       okx(35),
+			settings:   settings,
+			wantRule:   0,
+			wantActive: true,
+		},
+		{
+			name:       "still above threshold without significant change",
+			states:     activeState(now.Add(-2 * time.Hour)),
+			previous:   okx(35),
+			rate:       okx(35.5),
+			settings:   settings,
+			wantRule:   -1,
+			wantActive: true,
+		},
+		{
+			name:       "significant change after cooldown",
+			states:     activeState(now.Add(-2 * time.Hour)),
+			previous:   okx(35),
+			rate:       okx(40),
+			settings:   settings,
+			wantRule:   0,
+			wantActive: true,
+		},
+		{
+			name:       "significant change within cooldown",
+			states:     activeState(now.Add(-10 * time.Minute)),
+			previous:   okx(35),
+			rate:       okx(40),
+			settings:   settings,
+			wantRule:   -1,
+			wantActive: true,
+		},
+		{
+			name:       "still active after restart without previous rate",
+			states:     activeState(now.Add(-2 * time.Hour)),
+			rate:       okx(35),
+			settings:   settings,
+			wantRule:   -1,
+			wantActive: true,
+		},
+		{
+			name:       "dip inside hysteresis band stays active",
+			states:     activeState(now.Add(-2 * time.Hour)),
+			previous:   okx(30.5),
+			rate:       okx(29),
+			settings:   settings,
+			wantRule:   -1,
+			wantActive: true,
+		},
+		{
+			name:       "drop past hysteresis band re-arms",
+			states:     activeState(now.Add(-2 * time.Hour)),
+			previous:   okx(30.5),
+			rate:       okx(27),
+			settings:   settings,
+			wantRule:   -1,
+			wantActive: false,
+		},
+		{
+			name:       "custom borrow rule",
+			rate:       Rate{Source: "Neptune", Token: "USDC", Category: "DEX", LendingRate: 5, BorrowRate: 6},
+			settings:   settings,
+			wantRule:   1,
+			wantActive: true,
+		},
+		{
+			name:     "CEX hidden",
+			rate:     okx(35),
+			settings: chatSettings{rules: settings.rules},
+			wantRule: -1,
+		},
+		{
+			name:     "token not watched",
+			rate:     okx(35),
+			settings: chatSettings{showCEX: true, rules: settings.rules, watched: map[string]bool{"TIA": true}},
+			wantRule: -1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var previous map[string]map[string]Rate
+			if tt.previous.Source != "" {
+				previous = map[string]map[string]Rate{tt.previous.Token: {tt.previous.Source: tt.previous}}
+			}
+			tracker := newAlertTracker(1, tt.states, now)
+
+			matches := evaluateAlertRules([]Rate{tt.rate}, previous, tt.settings, tracker)
+			if tt.wantRule < 0 {
+				if len(matches) != 0 {
+					t.Errorf("evaluateAlertRules() = %+v, want no matches", matches)
+				}
+			} else if len(matches) != 1 || matches[0].Rule.ID != tt.wantRule {
+				t.Errorf("evaluateAlertRules() = %+v, want a match for rule %d", matches, tt.wantRule)
+			}
+
+			active := false
+			for _, state := range tracker.states {
+				active = active || state.Active
+			}
+			if active != tt.wantActive {
+				t.Errorf("alert state active = %v, want %v", active, tt.wantActive)
+			}
+		})
+	}
+}
+
+func TestAlertFlappingIsSuppressed(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	settings := chatSettings{showCEX: true, rules: thresholdRules(map[string]float64{"USDT": 30})}
+
+	// A rate oscillating around the threshold every two minutes should alert once
+	var states []AlertState
+	previous := map[string]map[string]Rate{}
+	alerts := 0
+	for i := 0; i < 30; i++ {
+		lending := 31.0
+		if i%2 == 1 {
+			lending = 29.5
+		}
+		rate := Rate{Source: "Neptune", Token: "USDT", Category: "DEX", LendingRate: lending}
+
+		tracker := newAlertTracker(1, states, start.Add(time.Duration(i)*2*time.Minute))
+		alerts += len(evaluateAlertRules([]Rate{rate}, previous, settings, tracker))
+
+		// Persist state between runs like the cron loop does
+		states = nil
+		for _, state := range tracker.states {
+			states = append(states, *state)
+		}
+		previous = map[string]map[string]Rate{"USDT": {"Neptune": rate}}
+	}
+
+	if alerts != 1 {
+		t.Errorf("oscillating rate produced %d alerts, want 1", alerts)
+	}
+}
+
+func TestAlertRearmsAfterReset(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	settings := chatSettings{showCEX: true, rules: thresholdRules(map[string]float64{"USDT": 30})}
+
+	// A rate that falls well past the hysteresis band and crosses again
+	// alerts again, even within the cooldown
+	var states []AlertState
+	previous := map[string]map[string]Rate{}
+	alerts := 0
+	for i, lending := range []float64{31, 20, 31} {
+		rate := Rate{Source: "Neptune", Token: "USDT", Category: "DEX", LendingRate: lending}
+		tracker := newAlertTracker(1, states, start.Add(time.Duration(i)*2*time.Minute))
+		alerts += len(evaluateAlertRules([]Rate{rate}, previous, settings, tracker))
+
+		states = nil
+		for _, state := range tracker.states {
+			states = append(states, *state)
+		}
+		previous = map[string]map[string]Rate{"USDT": {"Neptune": rate}}
+	}
+
+	if alerts != 2 {
+		t.Errorf("rate crossing twice after a reset produced %d alerts, want 2", alerts)
+	}
+}
+
+func TestBorrowThresholdRules(t *testing.T) {
+	settings := chatSettings{
+		showCEX: true,
+		rules: borrowThresholdRules(map[string]BorrowThreshold{
+			"USDT": {Ceiling: 20, Floor: 5},
+			"USDC": {Floor: 3},
+		}),
+	}
+	if len(settings.rules) != 3 {
+		t.Fatalf("borrowThresholdRules() got %d rules, want 3", len(settings.rules))
+	}
+
+	previous := map[string]map[string]Rate{
+		"USDT": {"Neptune": {Source: "Neptune", Token: "USDT", BorrowRate: 12}},
+		"USDC": {"Neptune": {Source: "Neptune", Token: "USDC", BorrowRate: 4}},
+	}
+	rates := []Rate{
+		{Source: "Neptune", Token: "USDT", BorrowRate: 25}, // above ceiling
+		{Source: "Neptune", Token: "USDC", BorrowRate: 2},  // below floor
+	}
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	matches := evaluateAlertRules(rates, previous, settings, newAlertTracker(1, nil, now))
+	if len(matches) != 2 {
+		t.Fatalf("evaluateAlertRules() = %+v, want USDT ceiling and USDC floor matches", matches)
+	}
+
+	rates = []Rate{{Source: "Neptune", Token: "USDT", BorrowRate: 4}} // below floor
+	matches = evaluateAlertRules(rates, previous, settings, newAlertTracker(1, nil, now))
+	if len(matches) != 1 || matches[0].Rate.Token != "USDT" || matches[0].Rule.Operator != "<=" {
+		t.Errorf("evaluateAlertRules() = %+v, want USDT borrow floor match", matches)
+	}
+
+	// Lend-only venues report no borrow rate and must not trip the floor
+	rates = []Rate{{Source: "Binance", Token: "USDT", Category: "CEX", LendingRate: 8}}
+	matches = evaluateAlertRules(rates, nil, settings, newAlertTracker(1, nil, now))
+	if len(matches) != 0 {
+		t.Errorf("evaluateAlertRules() for lend-only rate = %+v, want no matches", matches)
+	}
+}
+
+func TestDetectRateDrops(t *testing.T) {
+	settings := chatSettings{showCEX: true, thresholds: map[string]float64{"USDT": 30}}
+
+	tests := []struct {
+		name         string
+		previous     float64
+		current      float64
+		wantDrop     bool
+		crossedBelow bool
+	}{
+		{"collapse below threshold", 45, 5, true, true},
+		{"just crossed below", 31, 29, true, true},
+		{"sharp drop still above threshold", 80, 35, true, false},
+		{"small drop above threshold", 40, 35, false, false},
+		{"was already below threshold", 25, 5, false, false},
+		{"rising rate", 35, 45, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previous := map[string]map[string]Rate{
+				"USDT": {"Injera": {Source: "Injera", Token: "USDT", Category: "DEX", LendingRate: tt.previous}},
+			}
+			rates := []Rate{{Source: "Injera", Token: "USDT", Category: "DEX", LendingRate: tt.current}}
+
+			drops := detectRateDrops(rates, previous, settings, newAlertTracker(1, nil, time.Now()))
+			if (len(drops) == 1) != tt.wantDrop {
+				t.Fatalf("detectRateDrops() = %+v, wantDrop %v", drops, tt.wantDrop)
+			}
+			if tt.wantDrop && drops[0].CrossedBelow != tt.crossedBelow {
+				t.Errorf("CrossedBelow = %v, want %v", drops[0].CrossedBelow, tt.crossedBelow)
+			}
+		})
+	}
+
+	// Tokens without a threshold or outside the watchlist are ignored
+	previous := map[string]map[string]Rate{"TIA": {"Neptune": {Source: "Neptune", Token: "TIA", LendingRate: 45}}}
+	rates := []Rate{{Source: "Neptune", Token: "TIA", LendingRate: 5}}
+	if drops := detectRateDrops(rates, previous, settings, newAlertTracker(1, nil, time.Now())); len(drops) != 0 {
+		t.Errorf("detectRateDrops() for token without threshold = %+v, want none", drops)
+	}
+
+	// Repeated drops for the same venue are held back during the cooldown
+	now := time.Now()
+	previous = map[string]map[string]Rate{"USDT": {"Injera": {Source: "Injera", Token: "USDT", LendingRate: 45}}}
+	rates = []Rate{{Source: "Injera", Token: "USDT", LendingRate: 5}}
+	tracker := newAlertTracker(1, nil, now)
+	if drops := detectRateDrops(rates, previous, settings, tracker); len(drops) != 1 {
+		t.Fatalf("detectRateDrops() = %+v, want one drop", drops)
+	}
+	tracker = newAlertTracker(1, tracker.changedStates(), now.Add(10*time.Minute))
+	if drops := detectRateDrops(rates, previous, settings, tracker); len(drops) != 0 {
+		t.Errorf("detectRateDrops() within cooldown = %+v, want none", drops)
+	}
+}
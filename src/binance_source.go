@@ -2,16 +2,24 @@ package main
 
 import (
 	"compress/gzip"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"slices"
+	"strconv"
 	"time"
 )
 
+const binanceSimpleEarnURL = "https://www.binance.com/bapi/earn/v1/friendly/finance-earn/simple-earn/homepage/details"
+
 type BinanceSimpleEarnSource struct {
-	client   *http.Client
-	Category string
+	client     *http.Client
+	APIURL     string
+	Assets     []string
+	Category   string
+	Convention RateConvention
 }
 
 type BinanceSimpleEarnFullResponse struct {
@@ -21,6 +29,9 @@ type BinanceSimpleEarnFullResponse struct {
 		List []struct {
 			Asset    string   `json:"asset"`
 			ApyRange []string `json:"apyRange"`
+			// Bonus rate paid on top of the base rate per amount band,
+			// e.g. {"0-200USDT": "0.05"}
+			TierAnnualPercentageRate map[string]string `json:"tierAnnualPercentageRate"`
 		} `json:"list"`
 	} `json:"data"`
 	Success bool `json:"success"`
@@ -31,14 +42,19 @@ func NewBinanceSimpleEarnSource() *BinanceSimpleEarnSource {
 		client: &http.Client{
 			Timeout: 10 * time.Second,
 		},
-		Category: "CEX",
+		APIURL:     binanceSimpleEarnURL,
+		Assets:     []string{"USDT", "FDUSD"},
+		Category:   "CEX",
+		Convention: dailyAPY, // Simple Earn pays interest daily
 	}
 }
 
-func (b *BinanceSimpleEarnSource) FetchRates() ([]Rate, error) {
-	url := "https://www.binance.com/bapi/earn/v1/friendly/finance-earn/simple-earn/homepage/details"
+func (b *BinanceSimpleEarnSource) Name() string {
+	return "Binance"
+}
 
-	req, err := http.NewRequest("GET", url, nil)
+func (b *BinanceSimpleEarnSource) FetchRates(ctx context.Context) ([]Rate, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", b.APIURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("creating request: %w", err)
 	}
@@ -53,9 +69,8 @@ func (b *BinanceSimpleEarnSource) FetchRates() ([]Rate, error) {
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, string(body))
+	if err := checkResponseStatus(resp); err != nil {
+		return nil, err
 	}
 
 	var reader io.Reader
@@ -86,7 +101,7 @@ func (b *BinanceSimpleEarnSource) FetchRates() ([]Rate, error) {
 
 	var rates []Rate
 	for _, product := range response.Data.List {
-		if product.Asset != "USDT" && product.Asset != "FDUSD" {
+		if !slices.Contains(b.Assets, product.Asset) {
 			continue
 		}
 
@@ -94,24 +109,37 @@ func (b *BinanceSimpleEarnSource) FetchRates() ([]Rate, error) {
 			continue
 		}
 
-		// Use the highest APY from the range
-		var maxApy float64
-		for _, apyStr := range product.ApyRange {
+		// Use the highest APY from the range, the lowest is the base rate
+		var minApy, maxApy float64
+		for i, apyStr := range product.ApyRange {
 			var apy float64
 			if _, err := fmt.Sscanf(apyStr, "%f", &apy); err == nil {
 				if apy > maxApy {
 					maxApy = apy
 				}
+				if i == 0 || apy < minApy {
+					minApy = apy
+				}
 			}
 		}
 
-		rates = append(rates, Rate{
-			Source:      "Binance",
-			Token:       product.Asset,
-			BorrowRate:  0,
-			LendingRate: maxApy * 100,
-			Category:    b.Category,
-		})
+		tiers := binanceTiers(minApy*100, product.TierAnnualPercentageRate)
+		lendingRate := maxApy * 100
+		if len(tiers) > 0 {
+			lendingRate = bestTierRate(tiers)
+		}
+
+		rates = append(rates, b.Convention.apply(Rate{
+			Source:         "Binance",
+			Token:          product.Asset,
+			BorrowRate:     0,
+			LendingRate:    lendingRate,
+			Category:       b.Category,
+			FetchedAt:      time.Now(),
+			RawLendingRate: lendingRate,
+			SourceURL:      b.APIURL,
+			Tiers:          tiers,
+		}))
 	}
 
 	if len(rates) == 0 {
@@ -120,3 +148,29 @@ func (b *BinanceSimpleEarnSource) FetchRates() ([]Rate, error) {
 
 	return rates, nil
 }
+
+// binanceTiers adds the bonus rate of each amount band to the base rate, and
+// pays only the base rate above the last band. Invalid bands are skipped.
+func binanceTiers(base float64, bonuses map[string]string) []RateTier {
+	var tiers []RateTier
+	var top float64
+	for band, bonusStr := range bonuses {
+		minAmount, maxAmount, err := parseTierBand(band)
+		if err != nil {
+			continue
+		}
+		bonus, err := strconv.ParseFloat(bonusStr, 64)
+		if err != nil {
+			continue
+		}
+		tiers = append(tiers, RateTier{MinAmount: minAmount, MaxAmount: maxAmount, LendingRate: base + bonus*100})
+		top = max(top, maxAmount)
+	}
+	if len(tiers) == 0 {
+		return nil
+	}
+
+	tiers = append(tiers, RateTier{MinAmount: top, LendingRate: base})
+	sortTiers(tiers)
+	return tiers
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -8,7 +9,7 @@ import (
 
 func TestBinanceSimpleEarnSource_FetchRates(t *testing.T) {
 	source := NewBinanceSimpleEarnSource()
-	rates, err := source.FetchRates()
+	rates, err := source.FetchRates(context.Background())
 
 	if err != nil {
 		t.Logf("Error fetching rates: %v", err)
@@ -1,17 +1,41 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 )
 
+const (
+	bybitProductDetailURL = "https://api2.bybit.com/s1/byfi/get-product-detail"
+	bybitProductListURL   = "https://api2.bybit.com/s1/byfi/get-saving-homepage-product-cards"
+)
+
+// bybitFlexibleSaving is the product type of flexible savings
+const bybitFlexibleSaving = 4
+
+// knownBybitProductIDs seeds the coin cache so the default coins resolve
+// without a discovery request
+var knownBybitProductIDs = map[string]string{
+	"USDT": "1",
+	"USDC": "2",
+}
+
 type BybitSource struct {
-	Category string
+	APIURL         string
+	ProductListURL string
+	Coins          []string // Coins resolved to flexible savings products through discovery
+	ProductIDs     []string // Product IDs fetched in addition to Coins
+	Category       string
+	Convention     RateConvention
+
+	products symbolCache[string]
 }
 
 type BybitResponse struct {
@@ -19,79 +43,199 @@ type BybitResponse struct {
 	RetMsg  string `json:"retMsg"`
 	Result  struct {
 		FlexibleSavingProductDetail struct {
-			TieredApyList []struct {
-				ApyE8 string `json:"apy_e8"`
-			} `json:"tiered_apy_list"`
-			Coin int    `json:"coin"`
-			Name string `json:"name"`
+			TieredApyList []BybitTier `json:"tiered_apy_list"`
+			Coin          int         `json:"coin"`
+			Name          string      `json:"name"`
 		} `json:"flexible_saving_product_detail"`
 	} `json:"result"`
 }
 
+// BybitProductListResponse lists the savings products available per coin
+type BybitProductListResponse struct {
+	RetCode int    `json:"retCode"`
+	RetMsg  string `json:"retMsg"`
+	Result  struct {
+		CoinProducts []struct {
+			CoinName       string `json:"coin_name"`
+			SavingProducts []struct {
+				ProductID   string `json:"product_id"`
+				ProductType int    `json:"product_type"`
+			} `json:"saving_products"`
+		} `json:"coin_products"`
+	} `json:"result"`
+}
+
+// BybitTier is an amount band of a savings product. Amounts and the APY are
+// scaled by 1e8.
+type BybitTier struct {
+	MinE8 string `json:"min_e8"`
+	MaxE8 string `json:"max_e8"`
+	ApyE8 string `json:"apy_e8"`
+}
+
 func NewBybitSource() *BybitSource {
 	return &BybitSource{
-		Category: "CEX",
+		APIURL:         bybitProductDetailURL,
+		ProductListURL: bybitProductListURL,
+		Coins:          []string{"USDT", "USDC"},
+		Category:       "CEX",
+		Convention:     dailyAPY, // Flexible savings pay interest daily
+		products:       symbolCache[string]{name: "Bybit products", seed: knownBybitProductIDs},
 	}
 }
 
-func (s *BybitSource) FetchRates() ([]Rate, error) {
-	url := "https://api2.bybit.com/s1/byfi/get-product-detail"
-	client := &http.Client{
-		Timeout: 30 * time.Second,
+func (s *BybitSource) Name() string {
+	return "Bybit"
+}
+
+// FetchRates fetches each product separately. Products that fail are logged
+// and skipped; if some succeed they are returned in a PartialError.
+func (s *BybitSource) FetchRates(ctx context.Context) ([]Rate, error) {
+	var discover func(context.Context) (map[string]string, error)
+	if s.ProductListURL != "" {
+		discover = s.discoverProducts
 	}
+	productIDs, unknown := s.products.resolve(ctx, s.Coins, s.ProductIDs, discover)
 
-	// We'll fetch both USDT and USDC
-	productIDs := []string{"1", "2"} // 1 for USDT, 2 for USDC
-	var rates []Rate
+	var errs []error
+	for _, coin := range unknown {
+		errs = append(errs, fmt.Errorf("no Bybit flexible savings product for %s", coin))
+	}
 
+	var rates []Rate
 	for _, productID := range productIDs {
-		payload := fmt.Sprintf(`{"product_type":4,"product_id":"%s"}`, productID)
-
-		req, err := http.NewRequest("POST", url, strings.NewReader(payload))
+		rate, ok, err := s.fetchProduct(ctx, productID)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create request: %v", err)
+			err = fmt.Errorf("product %s: %w", productID, err)
+			log.Printf("Error fetching Bybit rates: %v", err)
+			errs = append(errs, err)
+			continue
+		}
+		if ok {
+			rates = append(rates, rate)
 		}
+	}
 
-		// Add required headers
-		req.Header.Add("Content-Type", "application/json")
-		req.Header.Add("Accept", "*/*")
-		req.Header.Add("Referer", "https://www.bybit.com/")
+	return partialResult(rates, errs)
+}
 
-		resp, err := client.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("failed to fetch bybit rates: %v", err)
-		}
-		defer resp.Body.Close()
+// fetchProduct fetches a flexible savings product's tiers. It reports false
+// if the product has no tiers.
+func (s *BybitSource) fetchProduct(ctx context.Context, productID string) (Rate, bool, error) {
+	payload := fmt.Sprintf(`{"product_type":%d,"product_id":"%s"}`, bybitFlexibleSaving, productID)
 
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read bybit response: %v", err)
-		}
+	var response BybitResponse
+	if err := s.post(ctx, s.APIURL, payload, &response); err != nil {
+		return Rate{}, false, err
+	}
+	if response.RetCode != 0 {
+		return Rate{}, false, fmt.Errorf("bybit API error: %s (code: %d)", response.RetMsg, response.RetCode)
+	}
 
-		var response BybitResponse
-		if err := json.Unmarshal(body, &response); err != nil {
-			return nil, fmt.Errorf("failed to parse bybit response: %v, body: %s", err, string(body))
-		}
+	tiers, err := parseBybitTiers(response.Result.FlexibleSavingProductDetail.TieredApyList)
+	if err != nil {
+		return Rate{}, false, err
+	}
+	if len(tiers) == 0 {
+		return Rate{}, false, nil
+	}
+
+	apy := bestTierRate(tiers)
+	return s.Convention.apply(Rate{
+		Token:          response.Result.FlexibleSavingProductDetail.Name,
+		LendingRate:    apy,
+		BorrowRate:     0,
+		Source:         "Bybit",
+		Category:       s.Category,
+		FetchedAt:      time.Now(),
+		RawLendingRate: apy,
+		SourceURL:      s.APIURL,
+		Tiers:          tiers,
+	}), true, nil
+}
+
+// post sends a JSON payload and decodes the JSON response into result
+func (s *BybitSource) post(ctx context.Context, url, payload string, result interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+
+	// Add required headers
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("Accept", "*/*")
+	req.Header.Add("Referer", "https://www.bybit.com/")
 
-		if response.RetCode != 0 {
-			return nil, fmt.Errorf("bybit API error: %s (code: %d)", response.RetMsg, response.RetCode)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch bybit data: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponseStatus(resp); err != nil {
+		return fmt.Errorf("failed to fetch bybit data: %w", err)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read bybit response: %w", err)
+	}
+
+	if err := json.Unmarshal(body, result); err != nil {
+		return fmt.Errorf("failed to parse bybit response: %v, body: %s", err, string(body))
+	}
+	return nil
+}
+
+// discoverProducts maps each coin to its flexible savings product ID
+func (s *BybitSource) discoverProducts(ctx context.Context) (map[string]string, error) {
+	var response BybitProductListResponse
+	payload := fmt.Sprintf(`{"product_type":%d}`, bybitFlexibleSaving)
+	if err := s.post(ctx, s.ProductListURL, payload, &response); err != nil {
+		return nil, err
+	}
+	if response.RetCode != 0 {
+		return nil, fmt.Errorf("bybit API error: %s (code: %d)", response.RetMsg, response.RetCode)
+	}
+
+	products := make(map[string]string, len(response.Result.CoinProducts))
+	for _, coin := range response.Result.CoinProducts {
+		for _, product := range coin.SavingProducts {
+			if product.ProductType == bybitFlexibleSaving && coin.CoinName != "" {
+				products[coin.CoinName] = product.ProductID
+				break
+			}
 		}
+	}
+	return products, nil
+}
 
-		if len(response.Result.FlexibleSavingProductDetail.TieredApyList) > 1 {
-			apyE8, err := strconv.ParseInt(response.Result.FlexibleSavingProductDetail.TieredApyList[1].ApyE8, 10, 64)
+// parseBybitTiers converts a product's tiered APY list into tiers with the
+// APY in percent. A zero or negative maximum means the band is unbounded.
+func parseBybitTiers(list []BybitTier) ([]RateTier, error) {
+	var tiers []RateTier
+	for _, item := range list {
+		apyE8, err := strconv.ParseInt(item.ApyE8, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse apy value: %v", err)
+		}
+		tier := RateTier{LendingRate: float64(apyE8) / 1000000}
+		if item.MinE8 != "" {
+			minE8, err := strconv.ParseInt(item.MinE8, 10, 64)
 			if err != nil {
-				return nil, fmt.Errorf("failed to parse apy value: %v", err)
+				return nil, fmt.Errorf("failed to parse tier minimum: %v", err)
 			}
-			apy := float64(apyE8) / 1000000
-			rates = append(rates, Rate{
-				Token:       response.Result.FlexibleSavingProductDetail.Name,
-				LendingRate: apy,
-				BorrowRate:  0,
-				Source:      "Bybit",
-				Category:    s.Category,
-			})
+			tier.MinAmount = float64(minE8) / 1e8
 		}
+		if item.MaxE8 != "" {
+			maxE8, err := strconv.ParseInt(item.MaxE8, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse tier maximum: %v", err)
+			}
+			tier.MaxAmount = max(float64(maxE8)/1e8, 0)
+		}
+		tiers = append(tiers, tier)
 	}
-
-	return rates, nil
+	sortTiers(tiers)
+	return tiers, nil
 }
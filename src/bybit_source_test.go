@@ -1,12 +1,20 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestBybitSource_FetchRates(t *testing.T) {
 	source := NewBybitSource()
-	rates, err := source.FetchRates()
+	rates, err := source.FetchRates(context.Background())
 
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
@@ -44,3 +52,127 @@ func TestBybitSource_FetchRates(t *testing.T) {
 		}
 	}
 }
+
+func TestBybitSource_FetchRatesTiers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		name := "USDT"
+		if strings.Contains(string(body), `"product_id":"2"`) {
+			name = "USDC"
+		}
+		fmt.Fprintf(w, `{"retCode":0,"result":{"flexible_saving_product_detail":{"name":%q,"tiered_apy_list":[
+			{"min_e8":"50000000000","max_e8":"0","apy_e8":"2000000"},
+			{"min_e8":"0","max_e8":"50000000000","apy_e8":"10000000"}
+		]}}}`, name)
+	}))
+	defer server.Close()
+
+	source := NewBybitSource()
+	source.APIURL = server.URL
+
+	rates, err := source.FetchRates(context.Background())
+	if err != nil {
+		t.Fatalf("FetchRates() error = %v", err)
+	}
+	if len(rates) != 2 {
+		t.Fatalf("FetchRates() got %d rates, want 2", len(rates))
+	}
+
+	rate := rates[0]
+	if rate.LendingRate != 10 {
+		t.Errorf("FetchRates() lending rate = %v, want best tier 10", rate.LendingRate)
+	}
+	want := []RateTier{{MinAmount: 0, MaxAmount: 500, LendingRate: 10}, {MinAmount: 500, LendingRate: 2}}
+	if len(rate.Tiers) != 2 || rate.Tiers[0] != want[0] || rate.Tiers[1] != want[1] {
+		t.Errorf("FetchRates() tiers = %+v, want %+v", rate.Tiers, want)
+	}
+}
+
+func TestBybitSource_DiscoversProducts(t *testing.T) {
+	var listCalls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.URL.Path == "/list" {
+			listCalls++
+			w.Write([]byte(`{"retCode":0,"result":{"coin_products":[
+				{"coin_name":"ETH","saving_products":[{"product_id":"9","product_type":2},{"product_id":"16","product_type":4}]}
+			]}}`))
+			return
+		}
+		switch {
+		case strings.Contains(string(body), `"product_id":"16"`):
+			w.Write([]byte(`{"retCode":0,"result":{"flexible_saving_product_detail":{"name":"ETH","tiered_apy_list":[{"min_e8":"0","max_e8":"0","apy_e8":"3000000"}]}}}`))
+		case strings.Contains(string(body), `"product_id":"2"`):
+			// A failing product must not hide the others
+			w.Write([]byte(`{"retCode":10001,"retMsg":"product offline"}`))
+		default:
+			w.Write([]byte(`{"retCode":0,"result":{"flexible_saving_product_detail":{"name":"USDT","tiered_apy_list":[{"min_e8":"0","max_e8":"0","apy_e8":"5000000"}]}}}`))
+		}
+	}))
+	defer server.Close()
+
+	source := NewBybitSource()
+	source.APIURL = server.URL + "/detail"
+	source.ProductListURL = server.URL + "/list"
+	source.Coins = []string{"USDT", "USDC", "eth"}
+
+	for i := 0; i < 2; i++ {
+		_, err := source.FetchRates(context.Background())
+		var partial *PartialError
+		if !errors.As(err, &partial) || !strings.Contains(err.Error(), "product 2") {
+			t.Fatalf("FetchRates() error = %v, want product 2 reported as failed", err)
+		}
+		rates := partial.Rates
+		if len(rates) != 2 || rates[0].Token != "USDT" || rates[1].Token != "ETH" {
+			t.Fatalf("FetchRates() = %+v, want USDT and ETH", rates)
+		}
+		if rates[1].LendingRate != 3 {
+			t.Errorf("FetchRates() ETH rate = %v, want 3", rates[1].LendingRate)
+		}
+	}
+	if listCalls != 1 {
+		t.Errorf("product list fetched %d times, want 1", listCalls)
+	}
+}
+
+func TestBybitSource_AllProductsFail(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	source := NewBybitSource()
+	source.APIURL = server.URL
+	if _, err := source.FetchRates(context.Background()); err == nil || !isRetryable(err) {
+		t.Errorf("FetchRates() error = %v, want retryable error", err)
+	}
+}
+
+func TestBybitSource_UnknownCoinIsDegraded(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"retCode":0,"result":{"flexible_saving_product_detail":{"name":"USDT","tiered_apy_list":[{"min_e8":"0","max_e8":"0","apy_e8":"5000000"}]}}}`))
+	}))
+	defer server.Close()
+
+	source := NewBybitSource()
+	source.APIURL = server.URL
+	source.ProductListURL = ""
+	source.Coins = []string{"USDT", "DOGE"}
+	resilient := newTestResilientSource(source)
+
+	// A coin without a flexible savings product degrades the source but
+	// never reports it down
+	for i := 0; i < sourceDownAfter+1; i++ {
+		rates, _, err := fetchRates(context.Background(), resilient)
+		if err != nil || len(rates) != 1 || rates[0].Token != "USDT" {
+			t.Fatalf("fetchRates() = %+v, %v, want the USDT rate", rates, err)
+		}
+	}
+	health, _ := healthOf(sourceHealth, "Bybit")
+	if status := health.Status(time.Now()); status != SourceDegraded || !strings.Contains(health.LastError, "DOGE") {
+		t.Errorf("Bybit health = %+v with status %s, want degraded naming DOGE", health, status)
+	}
+	if resilient.BreakerState() != BreakerClosed {
+		t.Errorf("BreakerState() = %v, want closed", resilient.BreakerState())
+	}
+}
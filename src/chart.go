@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"sort"
+	"time"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	chartWidth        = 900
+	chartHeight       = 500
+	chartMarginLeft   = 60
+	chartMarginRight  = 20
+	chartMarginTop    = 50
+	chartMarginBottom = 40
+	chartGridLines    = 5
+)
+
+var (
+	chartBackground = color.RGBA{255, 255, 255, 255}
+	chartAxisColor  = color.RGBA{60, 60, 60, 255}
+	chartGridColor  = color.RGBA{225, 225, 225, 255}
+	chartTextColor  = color.RGBA{30, 30, 30, 255}
+
+	// Fixed colors keep each venue recognisable across charts
+	sourceColors = map[string]color.RGBA{
+		"Binance": {240, 185, 11, 255},
+		"Bybit":   {247, 147, 26, 255},
+		"Injera":  {46, 160, 67, 255},
+		"Neptune": {31, 119, 180, 255},
+		"OKX":     {20, 20, 20, 255},
+	}
+	fallbackColors = []color.RGBA{
+		{214, 39, 40, 255},
+		{148, 103, 189, 255},
+		{140, 86, 75, 255},
+		{227, 119, 194, 255},
+	}
+)
+
+// chartSeries is a single line on the chart
+type chartSeries struct {
+	label  string
+	color  color.RGBA
+	dashed bool
+	times  []time.Time
+	values []float64
+}
+
+// renderRateChart draws the lending (and optionally borrow) rate history of a
+// token, converted to kind, as a PNG line chart with one line per source
+func renderRateChart(token string, history []Rate, includeBorrow bool, kind RateKind) ([]byte, error) {
+	if len(history) == 0 {
+		return nil, fmt.Errorf("no history to chart")
+	}
+
+	history = normalizeRates(history, kind)
+	series := buildChartSeries(history, includeBorrow)
+
+	start, end := history[0].FetchedAt, history[0].FetchedAt
+	maxValue := 0.0
+	for _, s := range series {
+		for i, t := range s.times {
+			if t.Before(start) {
+				start = t
+			}
+			if t.After(end) {
+				end = t
+			}
+			maxValue = max(maxValue, s.values[i])
+		}
+	}
+	if !end.After(start) {
+		end = start.Add(time.Hour)
+	}
+	if maxValue <= 0 {
+		maxValue = 1
+	}
+	maxValue *= 1.1
+
+	img := image.NewRGBA(image.Rect(0, 0, chartWidth, chartHeight))
+	draw.Draw(img, img.Bounds(), image.NewUniform(chartBackground), image.Point{}, draw.Src)
+
+	plotLeft, plotRight := chartMarginLeft, chartWidth-chartMarginRight
+	plotTop, plotBottom := chartMarginTop, chartHeight-chartMarginBottom
+
+	toX := func(t time.Time) int {
+		ratio := float64(t.Sub(start)) / float64(end.Sub(start))
+		return plotLeft + int(ratio*float64(plotRight-plotLeft))
+	}
+	toY := func(v float64) int {
+		return plotBottom - int(v/maxValue*float64(plotBottom-plotTop))
+	}
+
+	// Horizontal grid lines with rate labels
+	for i := 0; i <= chartGridLines; i++ {
+		value := maxValue * float64(i) / chartGridLines
+		y := toY(value)
+		drawLine(img, plotLeft, y, plotRight, y, chartGridColor, false)
+		drawText(img, 5, y+4, fmt.Sprintf("%5.1f%%", value), chartTextColor)
+	}
+
+	// Time labels at both ends and the middle
+	timeFormat := "01-02 15:04"
+	mid := start.Add(end.Sub(start) / 2)
+	drawText(img, plotLeft, plotBottom+20, start.Format(timeFormat), chartTextColor)
+	drawText(img, toX(mid)-38, plotBottom+20, mid.Format(timeFormat), chartTextColor)
+	drawText(img, plotRight-77, plotBottom+20, end.Format(timeFormat), chartTextColor)
+
+	drawLine(img, plotLeft, plotTop, plotLeft, plotBottom, chartAxisColor, false)
+	drawLine(img, plotLeft, plotBottom, plotRight, plotBottom, chartAxisColor, false)
+
+	for _, s := range series {
+		for i := 1; i < len(s.times); i++ {
+			drawLine(img, toX(s.times[i-1]), toY(s.values[i-1]),
+				toX(s.times[i]), toY(s.values[i]), s.color, s.dashed)
+		}
+	}
+
+	// Title and legend
+	title := fmt.Sprintf("%s lending rates (%s)", token, kind)
+	if includeBorrow {
+		title = fmt.Sprintf("%s lending / borrow (dashed) rates (%s)", token, kind)
+	}
+	drawText(img, plotLeft, 18, title, chartTextColor)
+
+	legendX := plotLeft
+	for _, s := range series {
+		if s.dashed {
+			continue
+		}
+		draw.Draw(img, image.Rect(legendX, 29, legendX+12, 39), image.NewUniform(s.color), image.Point{}, draw.Src)
+		drawText(img, legendX+16, 39, s.label, chartTextColor)
+		legendX += 16 + len(s.label)*7 + 20
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// buildChartSeries splits history entries into per-source lines sorted by source
+func buildChartSeries(history []Rate, includeBorrow bool) []chartSeries {
+	bySource := make(map[string][]Rate)
+	for _, entry := range history {
+		bySource[entry.Source] = append(bySource[entry.Source], entry)
+	}
+
+	var sources []string
+	for source := range bySource {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	var series []chartSeries
+	for i, source := range sources {
+		c, ok := sourceColors[source]
+		if !ok {
+			c = fallbackColors[i%len(fallbackColors)]
+		}
+
+		lending := chartSeries{label: source, color: c}
+		borrow := chartSeries{label: source, color: c, dashed: true}
+		for _, entry := range bySource[source] {
+			lending.times = append(lending.times, entry.FetchedAt)
+			lending.values = append(lending.values, entry.LendingRate)
+			if entry.BorrowRate > 0 {
+				borrow.times = append(borrow.times, entry.FetchedAt)
+				borrow.values = append(borrow.values, entry.BorrowRate)
+			}
+		}
+
+		series = append(series, lending)
+		if includeBorrow && len(borrow.times) > 0 {
+			series = append(series, borrow)
+		}
+	}
+	return series
+}
+
+// drawLine draws a two pixel wide line using Bresenham's algorithm
+func drawLine(img *image.RGBA, x0, y0, x1, y1 int, c color.RGBA, dashed bool) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+
+	err := dx + dy
+	for step := 0; ; step++ {
+		if !dashed || (step/6)%2 == 0 {
+			img.SetRGBA(x0, y0, c)
+			img.SetRGBA(x0, y0+1, c)
+		}
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+func drawText(img *image.RGBA, x, y int, text string, c color.RGBA) {
+	drawer := &font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(c),
+		Face: basicfont.Face7x13,
+		Dot:  fixed.P(x, y),
+	}
+	drawer.DrawString(text)
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
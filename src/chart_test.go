@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+	"time"
+)
+
+func TestRenderRateChart(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var history []Rate
+	for i := 0; i < 24; i++ {
+		at := base.Add(time.Duration(i) * time.Hour)
+		history = append(history,
+			Rate{Source: "Neptune", Token: "USDT", LendingRate: 20 + float64(i), BorrowRate: 30 + float64(i), FetchedAt: at},
+			Rate{Source: "OKX", Token: "USDT", LendingRate: 10, FetchedAt: at},
+		)
+	}
+
+	for _, includeBorrow := range []bool{false, true} {
+		data, err := renderRateChart("USDT", history, includeBorrow, RateKindAPR)
+		if err != nil {
+			t.Fatalf("renderRateChart() error = %v", err)
+		}
+
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("renderRateChart() produced invalid PNG: %v", err)
+		}
+		if img.Bounds().Dx() != chartWidth || img.Bounds().Dy() != chartHeight {
+			t.Errorf("chart size = %v, want %dx%d", img.Bounds(), chartWidth, chartHeight)
+		}
+	}
+
+	if _, err := renderRateChart("USDT", nil, false, RateKindAPY); err == nil {
+		t.Error("renderRateChart() with no history should return an error")
+	}
+}
+
+func TestBuildChartSeries(t *testing.T) {
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	history := []Rate{
+		{Source: "OKX", LendingRate: 10, FetchedAt: at},
+		{Source: "Neptune", LendingRate: 20, BorrowRate: 25, FetchedAt: at},
+	}
+
+	if got := len(buildChartSeries(history, false)); got != 2 {
+		t.Errorf("buildChartSeries() without borrow got %d series, want 2", got)
+	}
+
+	// OKX has no borrow rate so only Neptune gains a borrow line
+	series := buildChartSeries(history, true)
+	if len(series) != 3 {
+		t.Fatalf("buildChartSeries() with borrow got %d series, want 3", len(series))
+	}
+	if series[0].label != "Neptune" || !series[1].dashed {
+		t.Errorf("buildChartSeries() unexpected order: %+v", series)
+	}
+}
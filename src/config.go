@@ -0,0 +1,387 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds the settings that can be changed without rebuilding the bot.
+// Settings left out of the config file keep their built-in defaults.
+type Config struct {
+	Schedule            string                     `yaml:"schedule"` // Cron spec for fetching rates
+	CacheDuration       time.Duration              `yaml:"cache_duration"`
+	RateChangeThreshold float64                    `yaml:"rate_change_threshold"`
+	RateDropThreshold   float64                    `yaml:"rate_drop_threshold"`
+	LendingThresholds   map[string]float64         `yaml:"lending_thresholds"`
+	BorrowThresholds    map[string]BorrowThreshold `yaml:"borrow_thresholds"`
+	AlertCooldown       time.Duration              `yaml:"alert_cooldown"`
+	AlertHysteresis     float64                    `yaml:"alert_hysteresis"`
+	SourceDownAfter     int                        `yaml:"source_down_after"`
+	SourceStaleAfter    time.Duration              `yaml:"source_stale_after"`
+	Sources             SourcesConfig              `yaml:"sources"`
+}
+
+// SourcesConfig configures the built-in sources. Empty token lists keep each
+// source's defaults.
+type SourcesConfig struct {
+	OKX     OKXConfig     `yaml:"okx"`
+	Neptune NeptuneConfig `yaml:"neptune"`
+	Injera  InjeraConfig  `yaml:"injera"`
+	Binance BinanceConfig `yaml:"binance"`
+	Bybit   BybitConfig   `yaml:"bybit"`
+
+	// Additional venues read from JSON endpoints, see JSONSourceConfig
+	JSON []JSONSourceConfig `yaml:"json"`
+
+	// Names for the chain denoms reported by Neptune and Injera
+	Denoms DenomsConfig `yaml:"denoms"`
+}
+
+// DenomsConfig extends the bundled denom registry
+type DenomsConfig struct {
+	AssetList string            `yaml:"asset_list"` // Path to a chain-registry assetlist.json
+	Symbols   map[string]string `yaml:"symbols"`    // denom -> symbol
+}
+
+type OKXConfig struct {
+	Disabled    bool     `yaml:"disabled"`
+	Tokens      []string `yaml:"tokens"`       // Symbols, resolved to currency IDs automatically
+	CurrencyIDs []int    `yaml:"currency_ids"` // Extra currencies by ID
+}
+
+type NeptuneConfig struct {
+	Disabled bool `yaml:"disabled"`
+}
+
+type InjeraConfig struct {
+	Disabled     bool     `yaml:"disabled"`
+	LCDEndpoints []string `yaml:"lcd_endpoints"` // Tried in order until one answers
+	Contract     string   `yaml:"contract"`
+}
+
+type BinanceConfig struct {
+	Disabled bool     `yaml:"disabled"`
+	Assets   []string `yaml:"assets"`
+}
+
+type BybitConfig struct {
+	Disabled   bool     `yaml:"disabled"`
+	Coins      []string `yaml:"coins"`       // Coins, resolved to flexible savings products automatically
+	ProductIDs []string `yaml:"product_ids"` // Extra products by ID
+}
+
+const defaultSchedule = "*/2 * * * *"
+
+// configMutex guards the settings replaced when the config is reloaded
+var configMutex sync.RWMutex
+
+// builtinConfig holds the defaults compiled into the bot
+var builtinConfig = currentConfig()
+
+// currentConfig returns the settings currently in effect
+func currentConfig() Config {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+	return Config{
+		Schedule:            defaultSchedule,
+		CacheDuration:       cacheDuration,
+		RateChangeThreshold: rateChangeThreshold,
+		RateDropThreshold:   rateDropThreshold,
+		LendingThresholds:   lendingThresholds,
+		BorrowThresholds:    borrowThresholds,
+		AlertCooldown:       alertCooldown,
+		AlertHysteresis:     alertHysteresis,
+		SourceDownAfter:     sourceDownAfter,
+		SourceStaleAfter:    sourceStaleAfter,
+	}
+}
+
+// loadConfig reads a YAML config file and fills in missing settings from the
+// built-in defaults
+func loadConfig(path string) (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	cfg.setDefaults()
+	return cfg, cfg.validate()
+}
+
+func (c *Config) setDefaults() {
+	if c.Schedule == "" {
+		c.Schedule = builtinConfig.Schedule
+	}
+	if c.CacheDuration == 0 {
+		c.CacheDuration = builtinConfig.CacheDuration
+	}
+	if c.RateChangeThreshold == 0 {
+		c.RateChangeThreshold = builtinConfig.RateChangeThreshold
+	}
+	if c.RateDropThreshold == 0 {
+		c.RateDropThreshold = builtinConfig.RateDropThreshold
+	}
+	if c.LendingThresholds == nil {
+		c.LendingThresholds = builtinConfig.LendingThresholds
+	}
+	if c.BorrowThresholds == nil {
+		c.BorrowThresholds = builtinConfig.BorrowThresholds
+	}
+	if c.AlertCooldown == 0 {
+		c.AlertCooldown = builtinConfig.AlertCooldown
+	}
+	if c.AlertHysteresis == 0 {
+		c.AlertHysteresis = builtinConfig.AlertHysteresis
+	}
+	if c.SourceDownAfter == 0 {
+		c.SourceDownAfter = builtinConfig.SourceDownAfter
+	}
+	if c.SourceStaleAfter == 0 {
+		c.SourceStaleAfter = builtinConfig.SourceStaleAfter
+	}
+}
+
+func (c Config) validate() error {
+	if _, err := cron.ParseStandard(c.Schedule); err != nil {
+		return fmt.Errorf("invalid schedule %q: %w", c.Schedule, err)
+	}
+	if c.CacheDuration < 0 || c.RateChangeThreshold < 0 || c.RateDropThreshold < 0 {
+		return fmt.Errorf("cache_duration, rate_change_threshold and rate_drop_threshold must not be negative")
+	}
+	if c.AlertCooldown < 0 || c.AlertHysteresis < 0 || c.SourceDownAfter < 0 || c.SourceStaleAfter < 0 {
+		return fmt.Errorf("alert_cooldown, alert_hysteresis, source_down_after and source_stale_after must not be negative")
+	}
+	for token, threshold := range c.LendingThresholds {
+		if threshold < 0 {
+			return fmt.Errorf("negative lending threshold for %s", token)
+		}
+	}
+	for token, threshold := range c.BorrowThresholds {
+		if threshold.Ceiling < 0 || threshold.Floor < 0 {
+			return fmt.Errorf("negative borrow threshold for %s", token)
+		}
+	}
+
+	if _, err := loadDenomRegistry(c.Sources.Denoms); err != nil {
+		return fmt.Errorf("invalid denoms: %w", err)
+	}
+
+	names := map[string]bool{"OKX": true, "Neptune": true, "Injera": true, "Binance": true, "Bybit": true}
+	for i := range c.Sources.JSON {
+		source := &c.Sources.JSON[i]
+		if err := source.validate(); err != nil {
+			return err
+		}
+		if names[source.Name] {
+			return fmt.Errorf("duplicate source name %q", source.Name)
+		}
+		names[source.Name] = true
+	}
+	return nil
+}
+
+// applyConfig makes the config's settings take effect
+func applyConfig(cfg Config) {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	cacheDuration = cfg.CacheDuration
+	rateChangeThreshold = cfg.RateChangeThreshold
+	rateDropThreshold = cfg.RateDropThreshold
+	lendingThresholds = cfg.LendingThresholds
+	borrowThresholds = cfg.BorrowThresholds
+	alertCooldown = cfg.AlertCooldown
+	alertHysteresis = cfg.AlertHysteresis
+	sourceDownAfter = cfg.SourceDownAfter
+	sourceStaleAfter = cfg.SourceStaleAfter
+}
+
+// buildSources creates the enabled sources, each wrapped with retries and a
+// circuit breaker
+func buildSources(cfg SourcesConfig) []RateSource {
+	var sources []RateSource
+
+	registry, err := loadDenomRegistry(cfg.Denoms)
+	if err != nil {
+		log.Printf("Error loading denoms, using the bundled registry: %v", err)
+		registry = defaultDenomRegistry()
+	}
+
+	if !cfg.OKX.Disabled {
+		source := NewOKXSource()
+		if len(cfg.OKX.Tokens) > 0 || len(cfg.OKX.CurrencyIDs) > 0 {
+			source.Tokens = cfg.OKX.Tokens
+			source.CurrencyIDs = cfg.OKX.CurrencyIDs
+		}
+		sources = append(sources, NewResilientSource(source))
+	}
+	if !cfg.Neptune.Disabled {
+		source := NewNeptuneSource()
+		source.Registry = registry
+		sources = append(sources, NewResilientSource(source))
+	}
+	if !cfg.Injera.Disabled {
+		source := NewInjeraSource()
+		source.Registry = registry
+		if len(cfg.Injera.LCDEndpoints) > 0 {
+			source.Client = NewCosmWasmClient(cfg.Injera.LCDEndpoints...)
+		}
+		if cfg.Injera.Contract != "" {
+			source.Contract = cfg.Injera.Contract
+		}
+		sources = append(sources, NewResilientSource(source))
+	}
+	if !cfg.Binance.Disabled {
+		source := NewBinanceSimpleEarnSource()
+		if len(cfg.Binance.Assets) > 0 {
+			source.Assets = cfg.Binance.Assets
+		}
+		sources = append(sources, NewResilientSource(source))
+	}
+	if !cfg.Bybit.Disabled {
+		source := NewBybitSource()
+		if len(cfg.Bybit.Coins) > 0 || len(cfg.Bybit.ProductIDs) > 0 {
+			source.Coins = cfg.Bybit.Coins
+			source.ProductIDs = cfg.Bybit.ProductIDs
+		}
+		sources = append(sources, NewResilientSource(source))
+	}
+	for _, sourceConfig := range cfg.JSON {
+		source, err := NewJSONSource(sourceConfig)
+		if err != nil {
+			log.Printf("Skipping JSON source: %v", err)
+			continue
+		}
+		sources = append(sources, NewResilientSource(source))
+	}
+
+	return sources
+}
+
+// sourceConfigs returns the config each enabled source is built from, keyed
+// by source name, so reloads can tell which sources changed
+func sourceConfigs(cfg SourcesConfig) map[string]string {
+	configs := make(map[string]string)
+	if !cfg.OKX.Disabled {
+		configs["OKX"] = fmt.Sprintf("%+v", cfg.OKX)
+	}
+	if !cfg.Neptune.Disabled {
+		configs["Neptune"] = fmt.Sprintf("%+v %+v", cfg.Neptune, cfg.Denoms)
+	}
+	if !cfg.Injera.Disabled {
+		configs["Injera"] = fmt.Sprintf("%+v %+v", cfg.Injera, cfg.Denoms)
+	}
+	if !cfg.Binance.Disabled {
+		configs["Binance"] = fmt.Sprintf("%+v", cfg.Binance)
+	}
+	if !cfg.Bybit.Disabled {
+		configs["Bybit"] = fmt.Sprintf("%+v", cfg.Bybit)
+	}
+	for _, sourceConfig := range cfg.JSON {
+		configs[sourceConfig.Name] = fmt.Sprintf("%+v", sourceConfig)
+	}
+	return configs
+}
+
+// Sources built from the current config, and the config of each
+var (
+	activeSources []RateSource
+	activeConfigs map[string]string
+	sourcesMutex  sync.RWMutex
+)
+
+// reloadSources builds the sources for cfg, keeping the running instance of
+// each source whose config is unchanged so its circuit breaker and caches
+// survive the reload. It returns the names of the sources that were removed.
+func reloadSources(cfg SourcesConfig) []string {
+	configs := sourceConfigs(cfg)
+	sources := buildSources(cfg)
+
+	sourcesMutex.Lock()
+	defer sourcesMutex.Unlock()
+
+	running := make(map[string]RateSource, len(activeSources))
+	for _, source := range activeSources {
+		running[source.Name()] = source
+	}
+	for i, source := range sources {
+		name := source.Name()
+		if previous, exists := running[name]; exists && activeConfigs[name] == configs[name] {
+			sources[i] = previous
+		}
+		delete(running, name)
+	}
+
+	var removed []string
+	for name := range running {
+		removed = append(removed, name)
+	}
+	sort.Strings(removed)
+
+	activeSources, activeConfigs = sources, configs
+	return removed
+}
+
+func currentSources() []RateSource {
+	sourcesMutex.RLock()
+	defer sourcesMutex.RUnlock()
+	return activeSources
+}
+
+// watchConfig reloads the config file on SIGHUP and whenever its
+// modification time changes, passing each valid config to reload. Invalid
+// configs are logged and the previous settings are kept. Watching stops when
+// the context is done.
+func watchConfig(ctx context.Context, path string, interval time.Duration, reload func(Config)) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	modTime := configModTime(path)
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		defer signal.Stop(hangup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hangup:
+				log.Printf("Received SIGHUP, reloading %s", path)
+			case <-ticker.C:
+				current := configModTime(path)
+				if current.Equal(modTime) {
+					continue
+				}
+				modTime = current
+				log.Printf("%s changed, reloading", path)
+			}
+
+			cfg, err := loadConfig(path)
+			if err != nil {
+				log.Printf("Error reloading config, keeping previous settings: %v", err)
+				continue
+			}
+			reload(cfg)
+		}
+	}()
+}
+
+func configModTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, `
+schedule: "*/5 * * * *"
+cache_duration: 10m
+alert_cooldown: 30m
+source_down_after: 5
+lending_thresholds:
+  USDT: 20
+borrow_thresholds:
+  USDC: {ceiling: 15}
+sources:
+  okx:
+    disabled: true
+  binance:
+    assets: [USDT]
+`)
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if cfg.Schedule != "*/5 * * * *" || cfg.CacheDuration != 10*time.Minute {
+		t.Errorf("loadConfig() schedule/cache = %q/%v, want */5 * * * * and 10m", cfg.Schedule, cfg.CacheDuration)
+	}
+	if cfg.AlertCooldown != 30*time.Minute || cfg.SourceDownAfter != 5 {
+		t.Errorf("loadConfig() cooldown/down after = %v/%d, want 30m and 5", cfg.AlertCooldown, cfg.SourceDownAfter)
+	}
+	if cfg.AlertHysteresis != builtinConfig.AlertHysteresis || cfg.SourceStaleAfter != builtinConfig.SourceStaleAfter {
+		t.Errorf("loadConfig() hysteresis/stale after = %v/%v, want built-in defaults", cfg.AlertHysteresis, cfg.SourceStaleAfter)
+	}
+	if len(cfg.LendingThresholds) != 1 || cfg.LendingThresholds["USDT"] != 20 {
+		t.Errorf("loadConfig() lending thresholds = %v, want only USDT:20", cfg.LendingThresholds)
+	}
+	if cfg.BorrowThresholds["USDC"] != (BorrowThreshold{Ceiling: 15}) {
+		t.Errorf("loadConfig() borrow thresholds = %v, want USDC ceiling 15", cfg.BorrowThresholds)
+	}
+
+	// Missing settings keep the built-in defaults
+	if cfg.RateChangeThreshold != builtinConfig.RateChangeThreshold || cfg.RateDropThreshold != builtinConfig.RateDropThreshold {
+		t.Errorf("loadConfig() thresholds = %v/%v, want built-in defaults", cfg.RateChangeThreshold, cfg.RateDropThreshold)
+	}
+
+	sources := buildSources(cfg.Sources)
+	names := make(map[string]RateSource)
+	for _, source := range sources {
+		names[source.Name()] = source
+	}
+	if _, exists := names["OKX"]; exists || len(sources) != 4 {
+		t.Errorf("buildSources() = %d sources including OKX %v, want 4 without OKX", len(sources), exists)
+	}
+	binance := names["Binance"].(*ResilientSource).source.(*BinanceSimpleEarnSource)
+	if len(binance.Assets) != 1 || binance.Assets[0] != "USDT" {
+		t.Errorf("buildSources() Binance assets = %v, want [USDT]", binance.Assets)
+	}
+}
+
+func TestLoadConfig_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	tests := map[string]string{
+		"bad schedule":       `schedule: "every minute"`,
+		"negative threshold": "lending_thresholds:\n  USDT: -1",
+		"negative cooldown":  "alert_cooldown: -1h",
+		"bad yaml":           "schedule: [",
+	}
+
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, "config.yaml")
+			writeConfig(t, path, content)
+			if _, err := loadConfig(path); err == nil {
+				t.Errorf("loadConfig() should fail for %q", content)
+			}
+		})
+	}
+
+	if _, err := loadConfig(filepath.Join(dir, "missing.yaml")); !os.IsNotExist(err) {
+		t.Errorf("loadConfig() for missing file error = %v, want not exist", err)
+	}
+}
+
+func TestWatchConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "cache_duration: 1m")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reloaded := make(chan Config, 1)
+	watchConfig(ctx, path, 10*time.Millisecond, func(cfg Config) { reloaded <- cfg })
+
+	// Make sure the modification time differs from the first write
+	time.Sleep(20 * time.Millisecond)
+	writeConfig(t, path, "cache_duration: 2m")
+	os.Chtimes(path, time.Now(), time.Now().Add(time.Second))
+
+	select {
+	case cfg := <-reloaded:
+		if cfg.CacheDuration != 2*time.Minute {
+			t.Errorf("watchConfig() reloaded cache duration %v, want 2m", cfg.CacheDuration)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("watchConfig() did not reload the changed file")
+	}
+}
+
+func TestReloadSources(t *testing.T) {
+	defer func() { activeSources, activeConfigs = nil, nil }()
+
+	byName := func() map[string]RateSource {
+		names := make(map[string]RateSource)
+		for _, source := range currentSources() {
+			names[source.Name()] = source
+		}
+		return names
+	}
+
+	cfg := builtinConfig.Sources
+	if removed := reloadSources(cfg); len(removed) != 0 {
+		t.Errorf("reloadSources() on start removed %v, want none", removed)
+	}
+	first := byName()
+
+	// Changing one source rebuilds only that source
+	cfg.Binance.Assets = []string{"USDT"}
+	if removed := reloadSources(cfg); len(removed) != 0 {
+		t.Errorf("reloadSources() removed %v, want none", removed)
+	}
+	second := byName()
+	for name, source := range second {
+		if reused := source == first[name]; reused == (name == "Binance") {
+			t.Errorf("reloadSources() reused %s = %v, want only Binance rebuilt", name, reused)
+		}
+	}
+
+	cfg.OKX.Disabled = true
+	removed := reloadSources(cfg)
+	if len(removed) != 1 || removed[0] != "OKX" {
+		t.Errorf("reloadSources() removed %v, want [OKX]", removed)
+	}
+	if _, exists := byName()["OKX"]; exists {
+		t.Error("reloadSources() kept the disabled OKX source")
+	}
+}
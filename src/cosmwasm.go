@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// CosmWasmClient runs smart queries against CosmWasm contracts through a
+// chain's LCD (REST) endpoints. Endpoints are tried in order and the last one
+// that answered is preferred for the next query.
+type CosmWasmClient struct {
+	Endpoints []string
+
+	mu        sync.Mutex
+	preferred int
+}
+
+func NewCosmWasmClient(endpoints ...string) *CosmWasmClient {
+	return &CosmWasmClient{Endpoints: endpoints}
+}
+
+// smartQueryURL builds the LCD URL for a smart query message
+func smartQueryURL(endpoint, contract string, msg interface{}) (string, error) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return "", fmt.Errorf("error encoding query for %s: %w", contract, err)
+	}
+	return fmt.Sprintf("%s/cosmwasm/wasm/v1/contract/%s/smart/%s",
+		strings.TrimRight(endpoint, "/"), contract, base64.URLEncoding.EncodeToString(data)), nil
+}
+
+// SmartQuery sends msg to a contract and decodes the query result into
+// result, failing over to the next endpoint when one errors
+func (c *CosmWasmClient) SmartQuery(ctx context.Context, contract string, msg interface{}, result interface{}) error {
+	if len(c.Endpoints) == 0 {
+		return fmt.Errorf("no LCD endpoints configured")
+	}
+
+	c.mu.Lock()
+	start := c.preferred
+	c.mu.Unlock()
+
+	var errs []error
+	for i := range c.Endpoints {
+		index := (start + i) % len(c.Endpoints)
+		err := c.query(ctx, c.Endpoints[index], contract, msg, result)
+		if err == nil {
+			c.mu.Lock()
+			c.preferred = index
+			c.mu.Unlock()
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// Endpoint returns the endpoint the next query will try first
+func (c *CosmWasmClient) Endpoint() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.Endpoints) == 0 {
+		return ""
+	}
+	return c.Endpoints[c.preferred%len(c.Endpoints)]
+}
+
+func (c *CosmWasmClient) query(ctx context.Context, endpoint, contract string, msg interface{}, result interface{}) error {
+	url, err := smartQueryURL(endpoint, contract, msg)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("error creating query request: %v", err)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error querying %s: %w", endpoint, err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponseStatus(resp); err != nil {
+		return fmt.Errorf("error querying %s: %w", endpoint, err)
+	}
+
+	var response struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return fmt.Errorf("error unmarshaling response from %s: %w", endpoint, err)
+	}
+	if err := json.Unmarshal(response.Data, result); err != nil {
+		return fmt.Errorf("error unmarshaling query result from %s: %w", endpoint, err)
+	}
+	return nil
+}
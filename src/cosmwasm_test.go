@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// decodeSmartQuery returns the JSON query message of a smart query request
+func decodeSmartQuery(t *testing.T, r *http.Request) string {
+	t.Helper()
+	_, encoded, ok := strings.Cut(r.URL.Path, "/smart/")
+	if !ok {
+		t.Fatalf("unexpected query path %s", r.URL.Path)
+	}
+	msg, err := base64.URLEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatalf("query %q is not base64: %v", encoded, err)
+	}
+	return string(msg)
+}
+
+func TestCosmWasmClient_SmartQuery(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/cosmwasm/wasm/v1/contract/inj1contract/smart/") {
+			t.Errorf("query path = %s, want contract inj1contract", r.URL.Path)
+		}
+		if msg := decodeSmartQuery(t, r); msg != `{"markets":{"start_after":"atom","limit":1}}` {
+			t.Errorf("query message = %s", msg)
+		}
+		w.Write([]byte(`{"data": [{"denom": "inj", "borrow_rate": "0.1"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewCosmWasmClient(server.URL + "/")
+	var markets []InjeraMarket
+	query := InjeraQuery{Markets: &InjeraMarketsQuery{StartAfter: "atom", Limit: 1}}
+	if err := client.SmartQuery(context.Background(), "inj1contract", query, &markets); err != nil {
+		t.Fatalf("SmartQuery() error = %v", err)
+	}
+	if len(markets) != 1 || markets[0].Denom != "inj" || markets[0].BorrowRate != "0.1" {
+		t.Errorf("SmartQuery() result = %+v", markets)
+	}
+}
+
+func TestCosmWasmClient_Failover(t *testing.T) {
+	var downCalls int
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downCalls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": {"denom": "inj"}}`))
+	}))
+	defer up.Close()
+
+	client := NewCosmWasmClient(down.URL, up.URL)
+	for i := 0; i < 2; i++ {
+		var market InjeraMarket
+		if err := client.SmartQuery(context.Background(), "inj1contract", InjeraQuery{}, &market); err != nil {
+			t.Fatalf("SmartQuery() error = %v", err)
+		}
+	}
+
+	// The working endpoint is remembered, so the second query skips the
+	// failing one
+	if downCalls != 1 {
+		t.Errorf("failing endpoint called %d times, want 1", downCalls)
+	}
+	if client.Endpoint() != up.URL {
+		t.Errorf("Endpoint() = %s, want %s", client.Endpoint(), up.URL)
+	}
+
+	client = NewCosmWasmClient(down.URL)
+	err := client.SmartQuery(context.Background(), "inj1contract", InjeraQuery{}, &InjeraMarket{})
+	if err == nil || !isRetryable(err) {
+		t.Errorf("SmartQuery() with every endpoint down error = %v, want retryable error", err)
+	}
+}
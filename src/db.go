@@ -2,6 +2,8 @@ package main
 
 import (
 	"database/sql"
+	"fmt"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -24,16 +26,94 @@ func NewDatabase(dbPath string) (*Database, error) {
 		CREATE TABLE IF NOT EXISTS user_preferences (
 			chat_id INTEGER PRIMARY KEY,
 			show_cex BOOLEAN NOT NULL DEFAULT 1,
+			rate_kind TEXT NOT NULL DEFAULT 'APY',
 			FOREIGN KEY(chat_id) REFERENCES subscribers(chat_id)
 		);
+		CREATE TABLE IF NOT EXISTS rate_history (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			source TEXT NOT NULL,
+			token TEXT NOT NULL,
+			category TEXT NOT NULL,
+			lending_rate REAL NOT NULL,
+			borrow_rate REAL NOT NULL,
+			fetched_at INTEGER NOT NULL,
+			rate_kind TEXT NOT NULL DEFAULT '',
+			compounds_per_year INTEGER NOT NULL DEFAULT 0
+		);
+		CREATE INDEX IF NOT EXISTS idx_rate_history_token_time
+			ON rate_history(token, fetched_at);
+		CREATE INDEX IF NOT EXISTS idx_rate_history_source_time
+			ON rate_history(source, token, fetched_at);
+		CREATE INDEX IF NOT EXISTS idx_rate_history_fetched_at
+			ON rate_history(fetched_at);
+		CREATE TABLE IF NOT EXISTS chat_thresholds (
+			chat_id INTEGER NOT NULL,
+			token TEXT NOT NULL,
+			threshold REAL NOT NULL,
+			PRIMARY KEY (chat_id, token)
+		);
+		CREATE TABLE IF NOT EXISTS borrow_thresholds (
+			chat_id INTEGER NOT NULL,
+			token TEXT NOT NULL,
+			ceiling REAL NOT NULL DEFAULT 0,
+			floor REAL NOT NULL DEFAULT 0,
+			PRIMARY KEY (chat_id, token)
+		);
+		CREATE TABLE IF NOT EXISTS subscriptions (
+			chat_id INTEGER NOT NULL,
+			token TEXT NOT NULL,
+			PRIMARY KEY (chat_id, token)
+		);
+		CREATE TABLE IF NOT EXISTS alert_rules (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chat_id INTEGER NOT NULL,
+			token TEXT NOT NULL DEFAULT '',
+			field TEXT NOT NULL,
+			operator TEXT NOT NULL,
+			value REAL NOT NULL,
+			source TEXT NOT NULL DEFAULT '',
+			category TEXT NOT NULL DEFAULT ''
+		);
+		CREATE INDEX IF NOT EXISTS idx_alert_rules_chat ON alert_rules(chat_id);
+		CREATE TABLE IF NOT EXISTS alert_state (
+			chat_id INTEGER NOT NULL,
+			token TEXT NOT NULL,
+			source TEXT NOT NULL,
+			rule_key TEXT NOT NULL,
+			active BOOLEAN NOT NULL DEFAULT 0,
+			last_alert_at INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (chat_id, token, source, rule_key)
+		);
 	`)
 	if err != nil {
 		return nil, err
 	}
 
+	// Columns added after a table was first created
+	if err := addColumnIfMissing(db, "user_preferences", "rate_kind", "TEXT NOT NULL DEFAULT 'APY'"); err != nil {
+		return nil, err
+	}
+	if err := addColumnIfMissing(db, "rate_history", "rate_kind", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return nil, err
+	}
+	if err := addColumnIfMissing(db, "rate_history", "compounds_per_year", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return nil, err
+	}
+
 	return &Database{db: db}, nil
 }
 
+// addColumnIfMissing adds a column to a table created by an older version
+func addColumnIfMissing(db *sql.DB, table, column, definition string) error {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&count)
+	if err != nil || count > 0 {
+		return err
+	}
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
 func (d *Database) AddSubscriber(chatID int64) error {
 	_, err := d.db.Exec("INSERT OR IGNORE INTO subscribers (chat_id) VALUES (?)", chatID)
 	return err
@@ -89,6 +169,29 @@ func (d *Database) GetShowCEX(chatID int64) (bool, error) {
 	return show, nil
 }
 
+func (d *Database) SetRateKind(chatID int64, kind RateKind) error {
+	_, err := d.db.Exec(`
+		INSERT INTO user_preferences (chat_id, rate_kind)
+		VALUES (?, ?)
+		ON CONFLICT(chat_id) DO UPDATE SET rate_kind = ?`,
+		chatID, kind, kind)
+	return err
+}
+
+func (d *Database) GetRateKind(chatID int64) (RateKind, error) {
+	var kind string
+	err := d.db.QueryRow(`
+		SELECT COALESCE(
+			(SELECT rate_kind FROM user_preferences WHERE chat_id = ?),
+			?
+		)`,
+		chatID, defaultRateKind).Scan(&kind)
+	if err != nil {
+		return defaultRateKind, err
+	}
+	return RateKind(kind), nil
+}
+
 func (d *Database) LoadPreferences() (map[int64]bool, error) {
 	preferences := make(map[int64]bool)
 	rows, err := d.db.Query(`
@@ -109,3 +212,287 @@ func (d *Database) LoadPreferences() (map[int64]bool, error) {
 	}
 	return preferences, nil
 }
+
+func (d *Database) SetThreshold(chatID int64, token string, threshold float64) error {
+	_, err := d.db.Exec(`
+		INSERT INTO chat_thresholds (chat_id, token, threshold)
+		VALUES (?, ?, ?)
+		ON CONFLICT(chat_id, token) DO UPDATE SET threshold = ?`,
+		chatID, token, threshold, threshold)
+	return err
+}
+
+func (d *Database) RemoveThreshold(chatID int64, token string) error {
+	_, err := d.db.Exec("DELETE FROM chat_thresholds WHERE chat_id = ? AND token = ?", chatID, token)
+	return err
+}
+
+func (d *Database) GetThresholds(chatID int64) (map[string]float64, error) {
+	rows, err := d.db.Query("SELECT token, threshold FROM chat_thresholds WHERE chat_id = ?", chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	thresholds := make(map[string]float64)
+	for rows.Next() {
+		var token string
+		var threshold float64
+		if err := rows.Scan(&token, &threshold); err != nil {
+			return nil, err
+		}
+		thresholds[token] = threshold
+	}
+	return thresholds, rows.Err()
+}
+
+func (d *Database) SetBorrowCeiling(chatID int64, token string, ceiling float64) error {
+	_, err := d.db.Exec(`
+		INSERT INTO borrow_thresholds (chat_id, token, ceiling)
+		VALUES (?, ?, ?)
+		ON CONFLICT(chat_id, token) DO UPDATE SET ceiling = ?`,
+		chatID, token, ceiling, ceiling)
+	return err
+}
+
+func (d *Database) SetBorrowFloor(chatID int64, token string, floor float64) error {
+	_, err := d.db.Exec(`
+		INSERT INTO borrow_thresholds (chat_id, token, floor)
+		VALUES (?, ?, ?)
+		ON CONFLICT(chat_id, token) DO UPDATE SET floor = ?`,
+		chatID, token, floor, floor)
+	return err
+}
+
+func (d *Database) RemoveBorrowThresholds(chatID int64, token string) error {
+	_, err := d.db.Exec("DELETE FROM borrow_thresholds WHERE chat_id = ? AND token = ?", chatID, token)
+	return err
+}
+
+func (d *Database) GetBorrowThresholds(chatID int64) (map[string]BorrowThreshold, error) {
+	rows, err := d.db.Query("SELECT token, ceiling, floor FROM borrow_thresholds WHERE chat_id = ?", chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	thresholds := make(map[string]BorrowThreshold)
+	for rows.Next() {
+		var token string
+		var threshold BorrowThreshold
+		if err := rows.Scan(&token, &threshold.Ceiling, &threshold.Floor); err != nil {
+			return nil, err
+		}
+		thresholds[token] = threshold
+	}
+	return thresholds, rows.Err()
+}
+
+func (d *Database) AddSubscription(chatID int64, token string) error {
+	_, err := d.db.Exec("INSERT OR IGNORE INTO subscriptions (chat_id, token) VALUES (?, ?)", chatID, token)
+	return err
+}
+
+func (d *Database) RemoveSubscription(chatID int64, token string) error {
+	_, err := d.db.Exec("DELETE FROM subscriptions WHERE chat_id = ? AND token = ?", chatID, token)
+	return err
+}
+
+func (d *Database) ClearSubscriptions(chatID int64) error {
+	_, err := d.db.Exec("DELETE FROM subscriptions WHERE chat_id = ?", chatID)
+	return err
+}
+
+func (d *Database) GetSubscriptions(chatID int64) ([]string, error) {
+	rows, err := d.db.Query("SELECT token FROM subscriptions WHERE chat_id = ? ORDER BY token", chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []string
+	for rows.Next() {
+		var token string
+		if err := rows.Scan(&token); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+func (d *Database) AddAlertRule(rule AlertRule) (int64, error) {
+	result, err := d.db.Exec(`
+		INSERT INTO alert_rules (chat_id, token, field, operator, value, source, category)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		rule.ChatID, rule.Token, rule.Field, rule.Operator, rule.Value, rule.Source, rule.Category)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// RemoveAlertRule deletes a chat's rule and reports whether it existed
+func (d *Database) RemoveAlertRule(chatID, id int64) (bool, error) {
+	result, err := d.db.Exec("DELETE FROM alert_rules WHERE chat_id = ? AND id = ?", chatID, id)
+	if err != nil {
+		return false, err
+	}
+	removed, err := result.RowsAffected()
+	if err != nil || removed == 0 {
+		return false, err
+	}
+
+	_, err = d.db.Exec("DELETE FROM alert_state WHERE chat_id = ? AND rule_key = ?",
+		chatID, AlertRule{ID: id}.key())
+	return true, err
+}
+
+func (d *Database) GetAlertStates(chatID int64) ([]AlertState, error) {
+	rows, err := d.db.Query(`
+		SELECT chat_id, token, source, rule_key, active, last_alert_at
+		FROM alert_state
+		WHERE chat_id = ?`, chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var states []AlertState
+	for rows.Next() {
+		var state AlertState
+		var lastAlertAt int64
+		if err := rows.Scan(&state.ChatID, &state.Token, &state.Source, &state.RuleKey,
+			&state.Active, &lastAlertAt); err != nil {
+			return nil, err
+		}
+		if lastAlertAt > 0 {
+			state.LastAlertAt = time.Unix(lastAlertAt, 0)
+		}
+		states = append(states, state)
+	}
+	return states, rows.Err()
+}
+
+func (d *Database) SaveAlertStates(states []AlertState) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO alert_state (chat_id, token, source, rule_key, active, last_alert_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(chat_id, token, source, rule_key)
+		DO UPDATE SET active = excluded.active, last_alert_at = excluded.last_alert_at`)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for _, state := range states {
+		var lastAlertAt int64
+		if !state.LastAlertAt.IsZero() {
+			lastAlertAt = state.LastAlertAt.Unix()
+		}
+		_, err := stmt.Exec(state.ChatID, state.Token, state.Source, state.RuleKey,
+			state.Active, lastAlertAt)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (d *Database) GetAlertRules(chatID int64) ([]AlertRule, error) {
+	rows, err := d.db.Query(`
+		SELECT id, chat_id, token, field, operator, value, source, category
+		FROM alert_rules
+		WHERE chat_id = ?
+		ORDER BY id`, chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []AlertRule
+	for rows.Next() {
+		var rule AlertRule
+		if err := rows.Scan(&rule.ID, &rule.ChatID, &rule.Token, &rule.Field,
+			&rule.Operator, &rule.Value, &rule.Source, &rule.Category); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// SaveRateHistory records a snapshot of rates fetched at the given time
+func (d *Database) SaveRateHistory(rates []Rate, fetchedAt time.Time) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO rate_history (source, token, category, lending_rate, borrow_rate, fetched_at,
+			rate_kind, compounds_per_year)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for _, rate := range rates {
+		_, err := stmt.Exec(rate.Source, rate.Token, rate.Category,
+			rate.LendingRate, rate.BorrowRate, fetchedAt.Unix(), rate.Kind, rate.CompoundsPerYear)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetRateHistory returns the recorded rates for a token within [from, to],
+// ordered by time. An empty source matches every source.
+func (d *Database) GetRateHistory(token, source string, from, to time.Time) ([]Rate, error) {
+	rows, err := d.db.Query(`
+		SELECT source, token, category, lending_rate, borrow_rate, fetched_at,
+			rate_kind, compounds_per_year
+		FROM rate_history
+		WHERE token = ? AND (? = '' OR source = ?) AND fetched_at BETWEEN ? AND ?
+		ORDER BY fetched_at, source`,
+		token, source, source, from.Unix(), to.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []Rate
+	for rows.Next() {
+		var entry Rate
+		var fetchedAt int64
+		if err := rows.Scan(&entry.Source, &entry.Token, &entry.Category,
+			&entry.LendingRate, &entry.BorrowRate, &fetchedAt,
+			&entry.Kind, &entry.CompoundsPerYear); err != nil {
+			return nil, err
+		}
+		entry.FetchedAt = time.Unix(fetchedAt, 0)
+		history = append(history, entry)
+	}
+	return history, rows.Err()
+}
+
+// PruneRateHistory deletes history recorded before the cutoff and returns
+// the number of removed rows
+func (d *Database) PruneRateHistory(before time.Time) (int64, error) {
+	result, err := d.db.Exec("DELETE FROM rate_history WHERE fetched_at < ?", before.Unix())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestDatabase(t *testing.T) *Database {
+	t.Helper()
+	database, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase() error = %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+func TestDatabase_RateHistory(t *testing.T) {
+	database := newTestDatabase(t)
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	snapshots := []struct {
+		at    time.Time
+		rates []Rate
+	}{
+		{
+			at: base,
+			rates: []Rate{
+				{Source: "Neptune", Token: "USDT", Category: "DEX", LendingRate: 20, BorrowRate: 25},
+				{Source: "OKX", Token: "USDT", Category: "CEX", LendingRate: 10, BorrowRate: 12},
+			},
+		},
+		{
+			at: base.Add(time.Hour),
+			rates: []Rate{
+				{Source: "Neptune", Token: "USDT", Category: "DEX", LendingRate: 40, BorrowRate: 45},
+				{Source: "Neptune", Token: "USDC", Category: "DEX", LendingRate: 15, BorrowRate: 18,
+					Kind: RateKindAPY, CompoundsPerYear: 365},
+			},
+		},
+	}
+	for _, snapshot := range snapshots {
+		if err := database.SaveRateHistory(snapshot.rates, snapshot.at); err != nil {
+			t.Fatalf("SaveRateHistory() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		source  string
+		from    time.Time
+		to      time.Time
+		wantLen int
+	}{
+		{"all sources", "USDT", "", base, base.Add(2 * time.Hour), 3},
+		{"single source", "USDT", "Neptune", base, base.Add(2 * time.Hour), 2},
+		{"time range", "USDT", "", base.Add(30 * time.Minute), base.Add(2 * time.Hour), 1},
+		{"other token", "USDC", "", base, base.Add(2 * time.Hour), 1},
+		{"unknown token", "TIA", "", base, base.Add(2 * time.Hour), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			history, err := database.GetRateHistory(tt.token, tt.source, tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("GetRateHistory() error = %v", err)
+			}
+			if len(history) != tt.wantLen {
+				t.Errorf("GetRateHistory() got %d entries, want %d", len(history), tt.wantLen)
+			}
+			for i := 1; i < len(history); i++ {
+				if history[i].FetchedAt.Before(history[i-1].FetchedAt) {
+					t.Errorf("GetRateHistory() entries not ordered by time")
+				}
+			}
+		})
+	}
+
+	usdc, err := database.GetRateHistory("USDC", "", base, base.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("GetRateHistory() error = %v", err)
+	}
+	if len(usdc) != 1 || usdc[0].Kind != RateKindAPY || usdc[0].CompoundsPerYear != 365 {
+		t.Errorf("GetRateHistory() = %+v, want the rate kind and compounding restored", usdc)
+	}
+
+	removed, err := database.PruneRateHistory(base.Add(30 * time.Minute))
+	if err != nil {
+		t.Fatalf("PruneRateHistory() error = %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("PruneRateHistory() removed %d entries, want 2", removed)
+	}
+
+	history, err := database.GetRateHistory("USDT", "", base, base.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("GetRateHistory() error = %v", err)
+	}
+	if len(history) != 1 || history[0].LendingRate != 40 {
+		t.Errorf("GetRateHistory() after prune = %+v, want single 40%% entry", history)
+	}
+}
+
+func TestDatabase_Thresholds(t *testing.T) {
+	database := newTestDatabase(t)
+
+	if err := database.SetThreshold(1, "USDT", 25); err != nil {
+		t.Fatalf("SetThreshold() error = %v", err)
+	}
+	if err := database.SetThreshold(1, "USDT", 20); err != nil {
+		t.Fatalf("SetThreshold() update error = %v", err)
+	}
+	if err := database.SetThreshold(1, "INJ", 10); err != nil {
+		t.Fatalf("SetThreshold() error = %v", err)
+	}
+	if err := database.SetThreshold(2, "USDT", 40); err != nil {
+		t.Fatalf("SetThreshold() error = %v", err)
+	}
+
+	thresholds, err := database.GetThresholds(1)
+	if err != nil {
+		t.Fatalf("GetThresholds() error = %v", err)
+	}
+	if len(thresholds) != 2 || thresholds["USDT"] != 20 || thresholds["INJ"] != 10 {
+		t.Errorf("GetThresholds() = %v, want USDT:20 INJ:10", thresholds)
+	}
+
+	if err := database.RemoveThreshold(1, "USDT"); err != nil {
+		t.Fatalf("RemoveThreshold() error = %v", err)
+	}
+	thresholds, err = database.GetThresholds(1)
+	if err != nil {
+		t.Fatalf("GetThresholds() error = %v", err)
+	}
+	if _, exists := thresholds["USDT"]; exists {
+		t.Errorf("GetThresholds() still contains removed USDT threshold: %v", thresholds)
+	}
+}
+
+func TestDatabase_Subscriptions(t *testing.T) {
+	database := newTestDatabase(t)
+
+	for _, token := range []string{"USDT", "USDC", "USDT"} {
+		if err := database.AddSubscription(1, token); err != nil {
+			t.Fatalf("AddSubscription() error = %v", err)
+		}
+	}
+	if err := database.AddSubscription(2, "TIA"); err != nil {
+		t.Fatalf("AddSubscription() error = %v", err)
+	}
+
+	tokens, err := database.GetSubscriptions(1)
+	if err != nil {
+		t.Fatalf("GetSubscriptions() error = %v", err)
+	}
+	if strings.Join(tokens, ",") != "USDC,USDT" {
+		t.Errorf("GetSubscriptions() = %v, want [USDC USDT]", tokens)
+	}
+
+	if err := database.RemoveSubscription(1, "USDC"); err != nil {
+		t.Fatalf("RemoveSubscription() error = %v", err)
+	}
+	tokens, err = database.GetSubscriptions(1)
+	if err != nil {
+		t.Fatalf("GetSubscriptions() error = %v", err)
+	}
+	if strings.Join(tokens, ",") != "USDT" {
+		t.Errorf("GetSubscriptions() after remove = %v, want [USDT]", tokens)
+	}
+
+	if err := database.ClearSubscriptions(1); err != nil {
+		t.Fatalf("ClearSubscriptions() error = %v", err)
+	}
+	if tokens, _ = database.GetSubscriptions(1); len(tokens) != 0 {
+		t.Errorf("GetSubscriptions() after clear = %v, want none", tokens)
+	}
+	if tokens, _ = database.GetSubscriptions(2); len(tokens) != 1 {
+		t.Errorf("ClearSubscriptions() removed other chats' tokens: %v", tokens)
+	}
+}
+
+func TestDatabase_AlertRules(t *testing.T) {
+	database := newTestDatabase(t)
+
+	rule := AlertRule{ChatID: 1, Token: "USDT", Field: AlertFieldLend, Operator: ">", Value: 25, Source: "Neptune"}
+	id, err := database.AddAlertRule(rule)
+	if err != nil {
+		t.Fatalf("AddAlertRule() error = %v", err)
+	}
+	if _, err := database.AddAlertRule(AlertRule{ChatID: 2, Field: AlertFieldBorrow, Operator: "<", Value: 8}); err != nil {
+		t.Fatalf("AddAlertRule() error = %v", err)
+	}
+
+	rules, err := database.GetAlertRules(1)
+	if err != nil {
+		t.Fatalf("GetAlertRules() error = %v", err)
+	}
+	rule.ID = id
+	if len(rules) != 1 || rules[0] != rule {
+		t.Errorf("GetAlertRules() = %+v, want [%+v]", rules, rule)
+	}
+
+	// Chats cannot remove each other's rules
+	removed, err := database.RemoveAlertRule(2, id)
+	if err != nil || removed {
+		t.Errorf("RemoveAlertRule() from another chat = %v, %v, want false, nil", removed, err)
+	}
+
+	removed, err = database.RemoveAlertRule(1, id)
+	if err != nil || !removed {
+		t.Errorf("RemoveAlertRule() = %v, %v, want true, nil", removed, err)
+	}
+}
+
+func TestDatabase_BorrowThresholds(t *testing.T) {
+	database := newTestDatabase(t)
+
+	if err := database.SetBorrowCeiling(1, "USDT", 20); err != nil {
+		t.Fatalf("SetBorrowCeiling() error = %v", err)
+	}
+	if err := database.SetBorrowFloor(1, "USDT", 5); err != nil {
+		t.Fatalf("SetBorrowFloor() error = %v", err)
+	}
+	if err := database.SetBorrowFloor(1, "USDC", 3); err != nil {
+		t.Fatalf("SetBorrowFloor() error = %v", err)
+	}
+
+	thresholds, err := database.GetBorrowThresholds(1)
+	if err != nil {
+		t.Fatalf("GetBorrowThresholds() error = %v", err)
+	}
+	if thresholds["USDT"] != (BorrowThreshold{Ceiling: 20, Floor: 5}) {
+		t.Errorf("GetBorrowThresholds()[USDT] = %+v, want ceiling 20 floor 5", thresholds["USDT"])
+	}
+	if thresholds["USDC"] != (BorrowThreshold{Floor: 3}) {
+		t.Errorf("GetBorrowThresholds()[USDC] = %+v, want floor 3 only", thresholds["USDC"])
+	}
+
+	if err := database.RemoveBorrowThresholds(1, "USDT"); err != nil {
+		t.Fatalf("RemoveBorrowThresholds() error = %v", err)
+	}
+	thresholds, err = database.GetBorrowThresholds(1)
+	if err != nil {
+		t.Fatalf("GetBorrowThresholds() error = %v", err)
+	}
+	if _, exists := thresholds["USDT"]; exists {
+		t.Errorf("GetBorrowThresholds() still contains removed USDT bounds: %v", thresholds)
+	}
+}
+
+func TestDatabase_AlertStates(t *testing.T) {
+	database := newTestDatabase(t)
+	alertedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	states := []AlertState{
+		{ChatID: 1, Token: "USDT", Source: "Neptune", RuleKey: "rule:1", Active: true, LastAlertAt: alertedAt},
+		{ChatID: 1, Token: "USDT", Source: "OKX", RuleKey: dropRuleKey},
+	}
+	if err := database.SaveAlertStates(states); err != nil {
+		t.Fatalf("SaveAlertStates() error = %v", err)
+	}
+
+	// Updating an existing state replaces it
+	states[0].Active = false
+	if err := database.SaveAlertStates(states[:1]); err != nil {
+		t.Fatalf("SaveAlertStates() update error = %v", err)
+	}
+
+	loaded, err := database.GetAlertStates(1)
+	if err != nil {
+		t.Fatalf("GetAlertStates() error = %v", err)
+	}
+	if len(loaded) != 2 {
+		t.Fatalf("GetAlertStates() got %d states, want 2", len(loaded))
+	}
+	for _, state := range loaded {
+		if state.RuleKey == "rule:1" && (state.Active || !state.LastAlertAt.Equal(alertedAt)) {
+			t.Errorf("GetAlertStates() rule:1 = %+v, want inactive with last alert %v", state, alertedAt)
+		}
+		if state.RuleKey == dropRuleKey && !state.LastAlertAt.IsZero() {
+			t.Errorf("GetAlertStates() drop = %+v, want zero last alert", state)
+		}
+	}
+
+	// Removing a rule clears its state
+	id, err := database.AddAlertRule(AlertRule{ChatID: 1, Field: AlertFieldLend, Operator: ">", Value: 10})
+	if err != nil {
+		t.Fatalf("AddAlertRule() error = %v", err)
+	}
+	ruleState := AlertState{ChatID: 1, Token: "USDT", Source: "OKX", RuleKey: AlertRule{ID: id}.key(), Active: true}
+	if err := database.SaveAlertStates([]AlertState{ruleState}); err != nil {
+		t.Fatalf("SaveAlertStates() error = %v", err)
+	}
+	if _, err := database.RemoveAlertRule(1, id); err != nil {
+		t.Fatalf("RemoveAlertRule() error = %v", err)
+	}
+	loaded, err = database.GetAlertStates(1)
+	if err != nil {
+		t.Fatalf("GetAlertStates() error = %v", err)
+	}
+	for _, state := range loaded {
+		if state.RuleKey == ruleState.RuleKey {
+			t.Errorf("GetAlertStates() still contains state of removed rule: %+v", state)
+		}
+	}
+}
+
+func TestDatabase_RateKind(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "old.db")
+
+	// A database created before rate_kind existed is migrated
+	old, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	_, err = old.Exec(`
+		CREATE TABLE user_preferences (chat_id INTEGER PRIMARY KEY, show_cex BOOLEAN NOT NULL DEFAULT 1);
+		INSERT INTO user_preferences (chat_id, show_cex) VALUES (1, 0);`)
+	old.Close()
+	if err != nil {
+		t.Fatalf("creating old schema: %v", err)
+	}
+
+	database, err := NewDatabase(path)
+	if err != nil {
+		t.Fatalf("NewDatabase() on old schema error = %v", err)
+	}
+	defer database.Close()
+
+	if kind, err := database.GetRateKind(1); err != nil || kind != RateKindAPY {
+		t.Errorf("GetRateKind() for migrated chat = %v, %v, want APY", kind, err)
+	}
+	if kind, err := database.GetRateKind(2); err != nil || kind != RateKindAPY {
+		t.Errorf("GetRateKind() for unknown chat = %v, %v, want APY", kind, err)
+	}
+
+	if err := database.SetRateKind(1, RateKindAPR); err != nil {
+		t.Fatalf("SetRateKind() error = %v", err)
+	}
+	if kind, _ := database.GetRateKind(1); kind != RateKindAPR {
+		t.Errorf("GetRateKind() = %v, want APR", kind)
+	}
+	if show, _ := database.GetShowCEX(1); show {
+		t.Error("SetRateKind() should keep the CEX preference")
+	}
+}
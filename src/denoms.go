@@ -0,0 +1,153 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+)
+
+// bundledAssetList is the Injective asset list in the cosmos chain-registry
+// format, used to name the denoms reported by Neptune and Injera
+//
+//go:embed assets/injective.assetlist.json
+var bundledAssetList []byte
+
+// defaultDenomDecimals is assumed for denoms without a known exponent
+const defaultDenomDecimals = 6
+
+// DenomAsset is the token a chain denom stands for
+type DenomAsset struct {
+	Symbol   string
+	Decimals int
+}
+
+// AssetList is a chain-registry assetlist.json. Only the fields needed to
+// name denoms are decoded.
+type AssetList struct {
+	ChainName string `json:"chain_name"`
+	Assets    []struct {
+		Base       string `json:"base"`
+		Display    string `json:"display"`
+		Symbol     string `json:"symbol"`
+		DenomUnits []struct {
+			Denom    string `json:"denom"`
+			Exponent int    `json:"exponent"`
+		} `json:"denom_units"`
+	} `json:"assets"`
+}
+
+// DenomRegistry maps chain denoms to token symbols and decimals
+type DenomRegistry struct {
+	assets map[string]DenomAsset
+}
+
+// parseAssetList reads the assets of a chain-registry asset list. Decimals
+// are the exponent of the display unit.
+func parseAssetList(data []byte) (map[string]DenomAsset, error) {
+	var list AssetList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("parsing asset list: %w", err)
+	}
+
+	assets := make(map[string]DenomAsset, len(list.Assets))
+	for _, asset := range list.Assets {
+		if asset.Base == "" || asset.Symbol == "" {
+			continue
+		}
+		decimals := defaultDenomDecimals
+		for _, unit := range asset.DenomUnits {
+			if unit.Denom == asset.Display {
+				decimals = unit.Exponent
+			}
+		}
+		assets[asset.Base] = DenomAsset{Symbol: asset.Symbol, Decimals: decimals}
+	}
+	return assets, nil
+}
+
+// loadDenomRegistry builds a registry from the bundled asset list, then an
+// optional asset list file, then symbol overrides, later entries winning
+func loadDenomRegistry(cfg DenomsConfig) (*DenomRegistry, error) {
+	assets, err := parseAssetList(bundledAssetList)
+	if err != nil {
+		return nil, fmt.Errorf("bundled asset list: %w", err)
+	}
+
+	if cfg.AssetList != "" {
+		data, err := os.ReadFile(cfg.AssetList)
+		if err != nil {
+			return nil, err
+		}
+		extra, err := parseAssetList(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", cfg.AssetList, err)
+		}
+		for denom, asset := range extra {
+			assets[denom] = asset
+		}
+	}
+
+	for denom, symbol := range cfg.Symbols {
+		asset, exists := assets[denom]
+		if !exists {
+			asset.Decimals = defaultDenomDecimals
+		}
+		asset.Symbol = symbol
+		assets[denom] = asset
+	}
+	return &DenomRegistry{assets: assets}, nil
+}
+
+// defaultDenomRegistry returns the registry of the bundled asset list
+func defaultDenomRegistry() *DenomRegistry {
+	registry, err := loadDenomRegistry(DenomsConfig{})
+	if err != nil {
+		log.Printf("Error loading denom registry: %v", err)
+		return &DenomRegistry{}
+	}
+	return registry
+}
+
+// Lookup returns the token a denom stands for
+func (r *DenomRegistry) Lookup(denom string) (DenomAsset, bool) {
+	if r == nil {
+		return DenomAsset{}, false
+	}
+	asset, ok := r.assets[denom]
+	return asset, ok
+}
+
+// unknownDenoms tracks the denoms a source could not name in its latest
+// fetch, logging each one the first time it appears
+type unknownDenoms struct {
+	mu     sync.Mutex
+	denoms map[string]bool
+}
+
+// update replaces the unknown denoms seen by source
+func (u *unknownDenoms) update(source string, denoms map[string]bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for denom := range denoms {
+		if !u.denoms[denom] {
+			log.Printf("%s reports a market with unknown denom %s, add it to the denom registry", source, denom)
+		}
+	}
+	u.denoms = denoms
+}
+
+// UnknownDenoms returns the denoms that could not be named, sorted
+func (u *unknownDenoms) UnknownDenoms() []string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	var denoms []string
+	for denom := range u.denoms {
+		denoms = append(denoms, denom)
+	}
+	sort.Strings(denoms)
+	return denoms
+}
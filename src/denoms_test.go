@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseAssetList(t *testing.T) {
+	assets, err := parseAssetList(bundledAssetList)
+	if err != nil {
+		t.Fatalf("parseAssetList() error = %v", err)
+	}
+
+	tests := []struct {
+		denom string
+		want  DenomAsset
+	}{
+		{"inj", DenomAsset{Symbol: "INJ", Decimals: 18}},
+		{"peggy0xdAC17F958D2ee523a2206206994597C13D831ec7", DenomAsset{Symbol: "USDT", Decimals: 6}},
+		{"ibc/2CBC2EA121AE42563B08028466F37B600F2D7D4282342DE938283CC3FB2BC00E", DenomAsset{Symbol: "USDC", Decimals: 6}},
+		{"ibc/F51BB221BAA275F2EBF654F70B005627D7E713AFFD6D86AFD1E43CAA886149F4", DenomAsset{Symbol: "TIA", Decimals: 6}},
+	}
+	for _, tt := range tests {
+		if got := assets[tt.denom]; got != tt.want {
+			t.Errorf("bundled asset %s = %+v, want %+v", tt.denom, got, tt.want)
+		}
+	}
+}
+
+func TestLoadDenomRegistry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "assetlist.json")
+	writeConfig(t, path, `{"chain_name": "injective", "assets": [
+		{"base": "factory/inj1x/new", "display": "new", "symbol": "NEW",
+		 "denom_units": [{"denom": "factory/inj1x/new", "exponent": 0}, {"denom": "new", "exponent": 8}]},
+		{"base": "inj", "display": "inj", "symbol": "wINJ", "denom_units": [{"denom": "inj", "exponent": 18}]}
+	]}`)
+
+	registry, err := loadDenomRegistry(DenomsConfig{
+		AssetList: path,
+		Symbols:   map[string]string{"inj": "INJ", "factory/inj1x/other": "OTHER"},
+	})
+	if err != nil {
+		t.Fatalf("loadDenomRegistry() error = %v", err)
+	}
+
+	tests := []struct {
+		denom string
+		want  DenomAsset
+		found bool
+	}{
+		{"factory/inj1x/new", DenomAsset{Symbol: "NEW", Decimals: 8}, true},
+		{"inj", DenomAsset{Symbol: "INJ", Decimals: 18}, true}, // Symbols win over asset lists
+		{"factory/inj1x/other", DenomAsset{Symbol: "OTHER", Decimals: 6}, true},
+		{"peggy0xdAC17F958D2ee523a2206206994597C13D831ec7", DenomAsset{Symbol: "USDT", Decimals: 6}, true},
+		{"factory/inj1x/missing", DenomAsset{}, false},
+	}
+	for _, tt := range tests {
+		got, found := registry.Lookup(tt.denom)
+		if got != tt.want || found != tt.found {
+			t.Errorf("Lookup(%s) = %+v, %v, want %+v, %v", tt.denom, got, found, tt.want, tt.found)
+		}
+	}
+
+	writeConfig(t, path, "not json")
+	if _, err := loadDenomRegistry(DenomsConfig{AssetList: path}); err == nil {
+		t.Error("loadDenomRegistry() should fail for an invalid asset list")
+	}
+	if _, err := loadDenomRegistry(DenomsConfig{AssetList: filepath.Join(t.TempDir(), "missing.json")}); !os.IsNotExist(err) {
+		t.Errorf("loadDenomRegistry() for missing file error = %v, want not exist", err)
+	}
+}
+
+func TestNeptuneSource_UnknownDenoms(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{
+			"lend_aprs": [
+				[{"native_token": {"denom": "inj"}}, "0.02"],
+				[{"native_token": {"denom": "factory/inj1x/new"}}, "0.30"]
+			],
+			"borrow_aprs": [
+				[{"native_token": {"denom": "factory/inj1x/new"}}, "0.40"]
+			]
+		}`))
+	}))
+	defer server.Close()
+
+	source := NewNeptuneSource()
+	source.APIURL = server.URL
+	rates, err := source.FetchRates(context.Background())
+	if err != nil {
+		t.Fatalf("FetchRates() error = %v", err)
+	}
+	if len(rates) != 1 || rates[0].Token != "INJ" {
+		t.Errorf("FetchRates() = %+v, want only INJ", rates)
+	}
+
+	// Unknown denoms reach /sources through the health registry, also when
+	// the source is wrapped
+	health := NewHealthRegistry()
+	health.Record(NewResilientSource(source), rates, time.Millisecond, nil, time.Now())
+	if got, _ := healthOf(health, "Neptune"); !slices.Equal(got.UnknownDenoms, []string{"factory/inj1x/new"}) {
+		t.Errorf("UnknownDenoms = %v, want [factory/inj1x/new]", got.UnknownDenoms)
+	}
+
+	// Naming the denom in the registry clears it
+	registry, err := loadDenomRegistry(DenomsConfig{Symbols: map[string]string{"factory/inj1x/new": "NEW"}})
+	if err != nil {
+		t.Fatalf("loadDenomRegistry() error = %v", err)
+	}
+	source.Registry = registry
+	if rates, _ := source.FetchRates(context.Background()); len(rates) != 2 || len(source.UnknownDenoms()) != 0 {
+		t.Errorf("FetchRates() with override = %+v, unknown %v, want INJ and NEW", rates, source.UnknownDenoms())
+	}
+
+	// A response without a single known denom is an error, not an empty result
+	source.Registry = &DenomRegistry{}
+	if rates, err := source.FetchRates(context.Background()); err == nil {
+		t.Errorf("FetchRates() with no known denoms = %+v, want an error", rates)
+	}
+}
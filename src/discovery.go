@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// discoveryInterval limits how often a source's listing is fetched to
+// resolve symbols missing from its cache
+const discoveryInterval = time.Hour
+
+// symbolCache maps token symbols to the IDs an exchange API expects. It is
+// seeded with known IDs and asks the exchange for the rest, at most once per
+// discoveryInterval.
+type symbolCache[ID comparable] struct {
+	name string        // Describes the IDs in logs, e.g. "OKX currencies"
+	seed map[string]ID // Upper case symbol -> ID, known without discovery
+
+	mu           sync.Mutex
+	ids          map[string]ID
+	discoveredAt time.Time
+}
+
+// resolve returns extra followed by the IDs of symbols, without duplicates,
+// and the symbols that could not be resolved. discover lists the exchange's
+// symbols and is called when one is missing from the cache; it may be nil.
+func (c *symbolCache[ID]) resolve(ctx context.Context, symbols []string, extra []ID,
+	discover func(context.Context) (map[string]ID, error)) ([]ID, []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ids == nil {
+		c.ids = make(map[string]ID, len(c.seed))
+		maps.Copy(c.ids, c.seed)
+	}
+
+	missing := slices.ContainsFunc(symbols, func(symbol string) bool {
+		_, ok := c.ids[strings.ToUpper(symbol)]
+		return !ok
+	})
+	if missing && discover != nil && time.Since(c.discoveredAt) >= discoveryInterval {
+		c.discoveredAt = time.Now()
+		discovered, err := discover(ctx)
+		if err != nil {
+			log.Printf("Error discovering %s: %v", c.name, err)
+		}
+		for symbol, id := range discovered {
+			c.ids[strings.ToUpper(symbol)] = id
+		}
+	}
+
+	ids := slices.Clone(extra)
+	var unknown []string
+	for _, symbol := range symbols {
+		id, ok := c.ids[strings.ToUpper(symbol)]
+		if !ok {
+			unknown = append(unknown, symbol)
+			continue
+		}
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, unknown
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSymbolCache_Resolve(t *testing.T) {
+	var discoveries int
+	discover := func(ctx context.Context) (map[string]int, error) {
+		discoveries++
+		return map[string]int{"btc": 1, "USDT": 7}, nil
+	}
+	cache := symbolCache[int]{name: "test IDs", seed: map[string]int{"USDT": 7}}
+
+	// Known symbols resolve without discovery, after the extra IDs
+	ids, unknown := cache.resolve(context.Background(), []string{"usdt"}, []int{3, 7}, discover)
+	if len(ids) != 2 || ids[0] != 3 || ids[1] != 7 || len(unknown) != 0 || discoveries != 0 {
+		t.Errorf("resolve() = %v, %v after %d discoveries, want [3 7] without discovery", ids, unknown, discoveries)
+	}
+
+	// Missing symbols trigger one discovery per interval
+	for i := 0; i < 2; i++ {
+		ids, unknown = cache.resolve(context.Background(), []string{"BTC", "DOGE"}, nil, discover)
+		if len(ids) != 1 || ids[0] != 1 || len(unknown) != 1 || unknown[0] != "DOGE" {
+			t.Errorf("resolve() = %v, %v, want [1] with DOGE unknown", ids, unknown)
+		}
+	}
+	if discoveries != 1 {
+		t.Errorf("discovered %d times, want 1", discoveries)
+	}
+
+	// A failed discovery leaves the cache as it was
+	failing := symbolCache[int]{name: "test IDs"}
+	ids, unknown = failing.resolve(context.Background(), []string{"BTC"}, nil,
+		func(ctx context.Context) (map[string]int, error) { return nil, errors.New("boom") })
+	if len(ids) != 0 || len(unknown) != 1 {
+		t.Errorf("resolve() after failed discovery = %v, %v, want BTC unknown", ids, unknown)
+	}
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SourceHealth is the outcome of the most recent fetches from a source
+type SourceHealth struct {
+	Source              string
+	LastAttempt         time.Time
+	LastSuccess         time.Time
+	LastError           string
+	ConsecutiveFailures int
+	Latency             time.Duration
+	RateCount           int
+	Breaker             string    // Circuit breaker state, empty if the source has none
+	ValuesChangedAt     time.Time // When the returned rates last differed from the previous fetch
+	UnknownDenoms       []string  // Denoms in the last fetch the source could not name
+	Category            string    // CEX or DEX, from the last successful fetch
+	Tokens              []string  // Tokens in the last successful fetch
+	Degraded            bool      // The last fetch returned only some rates, LastError says why
+
+	fingerprint string
+}
+
+// Healthy reports whether the last fetch from the source succeeded
+func (h SourceHealth) Healthy() bool {
+	return h.ConsecutiveFailures == 0 && !h.LastSuccess.IsZero()
+}
+
+// SourceStatus classifies whether a source's data can be relied on
+type SourceStatus string
+
+const (
+	SourceOK       SourceStatus = "ok"
+	SourceDown     SourceStatus = "down"
+	SourceStale    SourceStatus = "stale"
+	SourceDegraded SourceStatus = "degraded"
+)
+
+// Status reports a source as down after SourceDownAfter consecutive failed
+// fetches, as degraded when its last fetch returned only some rates, and as
+// stale when it has returned identical rates for SourceStaleAfter, which
+// usually means the API is frozen. CEX savings products often keep a fixed
+// rate for days, so CEX sources are never stale.
+func (h SourceHealth) Status(now time.Time) SourceStatus {
+	cfg := currentConfig()
+	if h.ConsecutiveFailures >= cfg.SourceDownAfter {
+		return SourceDown
+	}
+	if h.Degraded {
+		return SourceDegraded
+	}
+	if h.Category == "CEX" {
+		return SourceOK
+	}
+	if h.RateCount > 0 && !h.ValuesChangedAt.IsZero() && now.Sub(h.ValuesChangedAt) >= cfg.SourceStaleAfter {
+		return SourceStale
+	}
+	return SourceOK
+}
+
+// breakerReporter is implemented by sources wrapped in a circuit breaker
+type breakerReporter interface {
+	BreakerState() BreakerState
+}
+
+// denomReporter is implemented by sources that name chain denoms
+type denomReporter interface {
+	UnknownDenoms() []string
+}
+
+// HealthRegistry records the health of each source, in the order the sources
+// were first seen
+type HealthRegistry struct {
+	mu      sync.RWMutex
+	sources map[string]*SourceHealth
+	order   []string
+}
+
+func NewHealthRegistry() *HealthRegistry {
+	return &HealthRegistry{sources: make(map[string]*SourceHealth)}
+}
+
+// Record stores the result of a fetch from a source. A PartialError counts as
+// a degraded success with the given rates, not as a failure.
+func (r *HealthRegistry) Record(source RateSource, rates []Rate, latency time.Duration, err error, at time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name := source.Name()
+	health, exists := r.sources[name]
+	if !exists {
+		health = &SourceHealth{Source: name}
+		r.sources[name] = health
+		r.order = append(r.order, name)
+	}
+
+	health.LastAttempt = at
+	health.Latency = latency
+	if reporter, ok := source.(breakerReporter); ok {
+		health.Breaker = reporter.BreakerState().String()
+	}
+	if reporter, ok := source.(denomReporter); ok {
+		health.UnknownDenoms = reporter.UnknownDenoms()
+	}
+	var partial *PartialError
+	health.Degraded = errors.As(err, &partial)
+	if err != nil && !health.Degraded {
+		health.LastError = err.Error()
+		health.ConsecutiveFailures++
+		return
+	}
+	health.LastSuccess = at
+	health.LastError = ""
+	if err != nil {
+		health.LastError = err.Error()
+	}
+	health.ConsecutiveFailures = 0
+	health.RateCount = len(rates)
+	health.Tokens = nil
+	for _, rate := range rates {
+		health.Category = rate.Category
+		if !slices.Contains(health.Tokens, rate.Token) {
+			health.Tokens = append(health.Tokens, rate.Token)
+		}
+	}
+
+	fingerprint := ratesFingerprint(rates)
+	if fingerprint != health.fingerprint {
+		health.fingerprint = fingerprint
+		health.ValuesChangedAt = at
+	}
+}
+
+// ratesFingerprint identifies a set of rates independent of their order
+func ratesFingerprint(rates []Rate) string {
+	entries := make([]string, 0, len(rates))
+	for _, rate := range rates {
+		entries = append(entries, fmt.Sprintf("%s|%s|%g|%g", rate.Source, rate.Token, rate.LendingRate, rate.BorrowRate))
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ";")
+}
+
+// Remove forgets a source that is no longer configured
+func (r *HealthRegistry) Remove(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.sources[name]; !exists {
+		return
+	}
+	delete(r.sources, name)
+	r.order = slices.DeleteFunc(r.order, func(source string) bool { return source == name })
+}
+
+// Snapshot returns a copy of every source's health
+func (r *HealthRegistry) Snapshot() []SourceHealth {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	snapshot := make([]SourceHealth, 0, len(r.order))
+	for _, name := range r.order {
+		snapshot = append(snapshot, *r.sources[name])
+	}
+	return snapshot
+}
+
+// formatAge renders a duration compactly, e.g. 45s, 14m, 3h or 2d
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+}
+
+// formatSourceHealth renders the /sources status table
+func formatSourceHealth(health []SourceHealth, now time.Time) string {
+	if len(health) == 0 {
+		return "No sources have been fetched yet."
+	}
+
+	var message strings.Builder
+	message.WriteString("*Source Status*\n")
+	for _, h := range health {
+		status := "✅"
+		if !h.Healthy() {
+			status = "❌"
+		} else if h.Degraded {
+			status = "⚠️"
+		}
+
+		lastSuccess := "never"
+		if !h.LastSuccess.IsZero() {
+			lastSuccess = formatAge(now.Sub(h.LastSuccess)) + " ago"
+		}
+		message.WriteString(fmt.Sprintf("%s `%-8s %3d rates %6s ok %s`\n",
+			status, h.Source, h.RateCount, h.Latency.Round(time.Millisecond), lastSuccess))
+
+		if h.ConsecutiveFailures > 0 {
+			message.WriteString(fmt.Sprintf("   `%d failure(s): %s`\n",
+				h.ConsecutiveFailures, truncateError(h.LastError, 80)))
+		}
+		if h.Degraded {
+			message.WriteString(fmt.Sprintf("   `partial: %s`\n", truncateError(h.LastError, 80)))
+		}
+		if h.Status(now) == SourceStale {
+			message.WriteString(fmt.Sprintf("   `values unchanged for %s`\n", formatAge(now.Sub(h.ValuesChangedAt))))
+		}
+		if h.Breaker != "" && h.Breaker != BreakerClosed.String() {
+			message.WriteString(fmt.Sprintf("   `circuit breaker %s`\n", h.Breaker))
+		}
+		if len(h.UnknownDenoms) > 0 {
+			message.WriteString(fmt.Sprintf("   `%d unknown denom(s): %s`\n",
+				len(h.UnknownDenoms), truncateError(strings.Join(h.UnknownDenoms, ", "), 80)))
+		}
+	}
+	return message.String()
+}
+
+// SourceStatusChange is a source moving between ok, down and stale
+type SourceStatusChange struct {
+	Health SourceHealth
+	From   SourceStatus
+	To     SourceStatus
+}
+
+// SourceMonitor remembers the last status reported for each source so that
+// subscribers are told once when a source becomes unreliable and once when it
+// recovers
+type SourceMonitor struct {
+	mu       sync.Mutex
+	reported map[string]SourceStatus
+}
+
+func NewSourceMonitor() *SourceMonitor {
+	return &SourceMonitor{reported: make(map[string]SourceStatus)}
+}
+
+// Update compares the current health of each source to the last reported
+// status and returns the changes. Sources start out as ok.
+func (m *SourceMonitor) Update(health []SourceHealth, now time.Time) []SourceStatusChange {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var changes []SourceStatusChange
+	for _, h := range health {
+		from, exists := m.reported[h.Source]
+		if !exists {
+			from = SourceOK
+		}
+		to := h.Status(now)
+		if to == from {
+			continue
+		}
+		m.reported[h.Source] = to
+		changes = append(changes, SourceStatusChange{Health: h, From: from, To: to})
+	}
+	return changes
+}
+
+// Remove forgets the status reported for a source that is no longer configured
+func (m *SourceMonitor) Remove(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.reported, name)
+}
+
+// formatSourceStatusChanges renders the notification for sources that became
+// unreliable or recovered
+func formatSourceStatusChanges(changes []SourceStatusChange, now time.Time) string {
+	var message strings.Builder
+	for _, change := range changes {
+		h := change.Health
+		switch change.To {
+		case SourceDown:
+			lastSuccess := "never"
+			if !h.LastSuccess.IsZero() {
+				lastSuccess = formatAge(now.Sub(h.LastSuccess)) + " ago"
+			}
+			message.WriteString(fmt.Sprintf("⚠️ *%s* data is unreliable: %d failed fetches in a row, last success %s.\n`%s`\n",
+				h.Source, h.ConsecutiveFailures, lastSuccess, truncateError(h.LastError, 120)))
+		case SourceDegraded:
+			message.WriteString(fmt.Sprintf("⚠️ *%s* is returning only some of its rates, the others are its last known values.\n`%s`\n",
+				h.Source, truncateError(h.LastError, 120)))
+		case SourceStale:
+			message.WriteString(fmt.Sprintf("⚠️ *%s* data is unreliable: rates unchanged for %s, the API may be frozen.\n",
+				h.Source, formatAge(now.Sub(h.ValuesChangedAt))))
+		default:
+			message.WriteString(fmt.Sprintf("✅ *%s* has recovered and is returning fresh data.\n", h.Source))
+		}
+	}
+	return message.String()
+}
+
+// truncateError shortens an error message for display inside a code span
+func truncateError(s string, limit int) string {
+	runes := []rune(strings.ReplaceAll(s, "`", "'"))
+	if len(runes) > limit {
+		return string(runes[:limit]) + "…"
+	}
+	return string(runes)
+}
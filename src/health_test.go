@@ -0,0 +1,257 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// healthOf finds a source in the registry's snapshot
+func healthOf(registry *HealthRegistry, name string) (SourceHealth, bool) {
+	for _, health := range registry.Snapshot() {
+		if health.Source == name {
+			return health, true
+		}
+	}
+	return SourceHealth{}, false
+}
+
+func TestHealthRegistry_Record(t *testing.T) {
+	registry := NewHealthRegistry()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	okx := &stubRateSource{name: "OKX"}
+	bybit := NewResilientSource(&stubRateSource{name: "Bybit"})
+
+	registry.Record(okx, make([]Rate, 12), 300*time.Millisecond, nil, now)
+	registry.Record(bybit, make([]Rate, 5), time.Second, nil, now)
+	registry.Record(bybit, nil, 2*time.Second, errors.New("timeout"), now.Add(2*time.Minute))
+	registry.Record(bybit, nil, 2*time.Second, errors.New("timeout"), now.Add(4*time.Minute))
+
+	snapshot := registry.Snapshot()
+	if len(snapshot) != 2 || snapshot[0].Source != "OKX" || snapshot[1].Source != "Bybit" {
+		t.Fatalf("Snapshot() = %+v, want OKX then Bybit", snapshot)
+	}
+	if !snapshot[0].Healthy() || snapshot[0].RateCount != 12 {
+		t.Errorf("OKX health = %+v, want healthy with 12 rates", snapshot[0])
+	}
+
+	bybitHealth := snapshot[1]
+	if bybitHealth.Healthy() || bybitHealth.ConsecutiveFailures != 2 || bybitHealth.LastError != "timeout" {
+		t.Errorf("Bybit health = %+v, want 2 failures with last error", bybitHealth)
+	}
+	if !bybitHealth.LastSuccess.Equal(now) || bybitHealth.RateCount != 5 {
+		t.Errorf("Bybit health = %+v, want last success and rate count kept from %v", bybitHealth, now)
+	}
+	if bybitHealth.Breaker != "closed" {
+		t.Errorf("Bybit breaker = %q, want closed", bybitHealth.Breaker)
+	}
+
+	registry.Record(bybit, make([]Rate, 6), time.Second, nil, now.Add(6*time.Minute))
+	if health, _ := healthOf(registry, "Bybit"); !health.Healthy() || health.LastError != "" {
+		t.Errorf("Bybit health after recovery = %+v, want healthy", health)
+	}
+}
+
+func TestFormatSourceHealth(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	message := formatSourceHealth([]SourceHealth{
+		{Source: "OKX", LastSuccess: now.Add(-2 * time.Minute), RateCount: 12, Latency: 300 * time.Millisecond},
+		{Source: "Bybit", ConsecutiveFailures: 3, LastError: "unexpected status code: 503", Breaker: "open"},
+		{Source: "Neptune", LastSuccess: now, RateCount: 3, UnknownDenoms: []string{"factory/a", "factory/b"}},
+	}, now)
+
+	for _, want := range []string{"✅ `OKX", "ok 2m ago", "❌ `Bybit", "never", "3 failure(s): unexpected status code: 503", "circuit breaker open",
+		"2 unknown denom(s): factory/a, factory/b"} {
+		if !strings.Contains(message, want) {
+			t.Errorf("formatSourceHealth() missing %q in:\n%s", want, message)
+		}
+	}
+}
+
+func TestSourceHealth_CEXNeverStale(t *testing.T) {
+	registry := NewHealthRegistry()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	rates := []Rate{{Source: "Bybit", Token: "USDT", Category: "CEX", LendingRate: 10}}
+
+	registry.Record(&stubRateSource{name: "Bybit"}, rates, time.Second, nil, now)
+	later := now.Add(2 * sourceStaleAfter)
+	registry.Record(&stubRateSource{name: "Bybit"}, rates, time.Second, nil, later)
+
+	health, _ := healthOf(registry, "Bybit")
+	if status := health.Status(later); status != SourceOK {
+		t.Errorf("Status() for unchanged CEX rates = %s, want ok", status)
+	}
+	if health.Category != "CEX" || strings.Join(health.Tokens, ",") != "USDT" {
+		t.Errorf("Record() category/tokens = %s/%v, want CEX/[USDT]", health.Category, health.Tokens)
+	}
+}
+
+func TestSourceChangesFor(t *testing.T) {
+	changes := []SourceStatusChange{
+		{Health: SourceHealth{Source: "Bybit", Category: "CEX", Tokens: []string{"USDT"}}, To: SourceDown},
+		{Health: SourceHealth{Source: "Neptune", Category: "DEX", Tokens: []string{"USDT", "TIA"}}, To: SourceDown},
+		{Health: SourceHealth{Source: "Injera", Category: "DEX", Tokens: []string{"INJ"}}, To: SourceStale},
+		{Health: SourceHealth{Source: "New"}, To: SourceDown}, // never fetched
+	}
+
+	tests := []struct {
+		name     string
+		settings chatSettings
+		want     string
+	}{
+		{"everything", chatSettings{showCEX: true}, "Bybit,Neptune,Injera,New"},
+		{"no CEX", chatSettings{}, "Neptune,Injera,New"},
+		{"watching TIA", chatSettings{showCEX: true, watched: map[string]bool{"TIA": true}}, "Neptune,New"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, change := range sourceChangesFor(changes, tt.settings) {
+				got = append(got, change.Health.Source)
+			}
+			if strings.Join(got, ",") != tt.want {
+				t.Errorf("sourceChangesFor() = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatAge(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{45 * time.Second, "45s"},
+		{14 * time.Minute, "14m"},
+		{3*time.Hour + 10*time.Minute, "3h"},
+		{50 * time.Hour, "2d"},
+	}
+	for _, tt := range tests {
+		if got := formatAge(tt.d); got != tt.want {
+			t.Errorf("formatAge(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestSourceMonitor_Update(t *testing.T) {
+	registry := NewHealthRegistry()
+	monitor := NewSourceMonitor()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	source := &stubRateSource{name: "Bybit"}
+	rates := []Rate{{Source: "Bybit", Token: "USDT", LendingRate: 10}}
+
+	registry.Record(source, rates, time.Second, nil, now)
+	if changes := monitor.Update(registry.Snapshot(), now); len(changes) != 0 {
+		t.Errorf("Update() for healthy source = %+v, want no changes", changes)
+	}
+
+	// Reported down only once the failure count reaches sourceDownAfter
+	for i := 1; i <= sourceDownAfter; i++ {
+		at := now.Add(time.Duration(i) * 2 * time.Minute)
+		registry.Record(source, nil, time.Second, errors.New("timeout"), at)
+		changes := monitor.Update(registry.Snapshot(), at)
+		if i < sourceDownAfter && len(changes) != 0 {
+			t.Errorf("Update() after %d failures = %+v, want no changes", i, changes)
+		}
+		if i == sourceDownAfter && (len(changes) != 1 || changes[0].To != SourceDown) {
+			t.Errorf("Update() after %d failures = %+v, want down", i, changes)
+		}
+	}
+
+	// Recovery is reported, and identical rates later become stale
+	registry.Record(source, rates, time.Second, nil, now.Add(time.Hour))
+	changes := monitor.Update(registry.Snapshot(), now.Add(time.Hour))
+	if len(changes) != 1 || changes[0].To != SourceOK {
+		t.Errorf("Update() after recovery = %+v, want ok", changes)
+	}
+
+	frozenAt := now.Add(time.Hour).Add(sourceStaleAfter)
+	registry.Record(source, rates, time.Second, nil, frozenAt)
+	changes = monitor.Update(registry.Snapshot(), frozenAt)
+	if len(changes) != 1 || changes[0].To != SourceStale {
+		t.Fatalf("Update() with frozen rates = %+v, want stale", changes)
+	}
+	if message := formatSourceStatusChanges(changes, frozenAt); !strings.Contains(message, "unchanged for 7h") {
+		t.Errorf("formatSourceStatusChanges() = %q, want unchanged duration", message)
+	}
+
+	changed := []Rate{{Source: "Bybit", Token: "USDT", LendingRate: 11}}
+	registry.Record(source, changed, time.Second, nil, frozenAt.Add(time.Minute))
+	changes = monitor.Update(registry.Snapshot(), frozenAt.Add(time.Minute))
+	if len(changes) != 1 || changes[0].From != SourceStale || changes[0].To != SourceOK {
+		t.Errorf("Update() after rates changed = %+v, want stale -> ok", changes)
+	}
+}
+
+func TestHealthRegistry_Remove(t *testing.T) {
+	registry := NewHealthRegistry()
+	monitor := NewSourceMonitor()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, name := range []string{"OKX", "Bybit"} {
+		for i := 0; i < sourceDownAfter; i++ {
+			registry.Record(&stubRateSource{name: name}, nil, time.Second, errors.New("timeout"), now)
+		}
+	}
+	monitor.Update(registry.Snapshot(), now)
+
+	registry.Remove("OKX")
+	monitor.Remove("OKX")
+	if snapshot := registry.Snapshot(); len(snapshot) != 1 || snapshot[0].Source != "Bybit" {
+		t.Errorf("Snapshot() after Remove() = %+v, want only Bybit", snapshot)
+	}
+	if _, exists := healthOf(registry, "OKX"); exists {
+		t.Error("Get() should not find a removed source")
+	}
+
+	// A source added back starts out as ok again
+	for i := 0; i < sourceDownAfter; i++ {
+		registry.Record(&stubRateSource{name: "OKX"}, nil, time.Second, errors.New("timeout"), now)
+	}
+	changes := monitor.Update(registry.Snapshot(), now)
+	if len(changes) != 1 || changes[0].Health.Source != "OKX" || changes[0].To != SourceDown {
+		t.Errorf("Update() after re-adding = %+v, want OKX down", changes)
+	}
+}
+
+func TestHealthRegistry_PartialFetches(t *testing.T) {
+	registry := NewHealthRegistry()
+	monitor := NewSourceMonitor()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	okx := &stubRateSource{name: "OKX"}
+	rates := []Rate{{Source: "OKX", Token: "USDT", Category: "CEX", LendingRate: 10}}
+	partial := &PartialError{Rates: rates, Err: errors.New("unknown OKX currency DOGE")}
+
+	var reported []SourceStatusChange
+	for i := 0; i < sourceDownAfter+1; i++ {
+		at := now.Add(time.Duration(i) * time.Minute)
+		registry.Record(okx, rates, time.Second, partial, at)
+		reported = append(reported, monitor.Update(registry.Snapshot(), at)...)
+	}
+
+	health, _ := healthOf(registry, "OKX")
+	if !health.Healthy() || !health.Degraded || health.RateCount != 1 || health.Category != "CEX" {
+		t.Errorf("health after partial fetches = %+v, want a degraded CEX success", health)
+	}
+	if len(reported) != 1 || reported[0].To != SourceDegraded {
+		t.Errorf("Update() over partial fetches = %+v, want one change to degraded", reported)
+	}
+
+	// Chats hiding CEX are not told about a degraded CEX source
+	if shown := sourceChangesFor(reported, chatSettings{showCEX: false}); len(shown) != 0 {
+		t.Errorf("sourceChangesFor() without CEX = %+v, want none", shown)
+	}
+
+	message := formatSourceHealth(registry.Snapshot(), now)
+	for _, want := range []string{"⚠️ `OKX", "partial: 1 rates fetched", "DOGE"} {
+		if !strings.Contains(message, want) {
+			t.Errorf("formatSourceHealth() missing %q in:\n%s", want, message)
+		}
+	}
+
+	// A complete fetch clears the degraded state
+	registry.Record(okx, rates, time.Second, nil, now.Add(time.Hour))
+	if health, _ := healthOf(registry, "OKX"); health.Degraded || health.LastError != "" {
+		t.Errorf("health after a complete fetch = %+v, want not degraded", health)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultHistoryPeriod = 7 * 24 * time.Hour
+
+// HistorySummary holds aggregated lending and borrow statistics for one source
+type HistorySummary struct {
+	Source      string
+	Samples     int
+	MinLending  float64
+	MaxLending  float64
+	AvgLending  float64
+	LastLending float64
+	MinBorrow   float64
+	MaxBorrow   float64
+	AvgBorrow   float64
+	LastBorrow  float64
+}
+
+// parsePeriod parses periods like "24h", "7d" or "2w".
+// An empty string yields the default history period.
+func parsePeriod(s string) (time.Duration, error) {
+	if s == "" {
+		return defaultHistoryPeriod, nil
+	}
+
+	s = strings.ToLower(s)
+	unit := s[len(s)-1]
+	value, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("invalid period: %s", s)
+	}
+
+	switch unit {
+	case 'h':
+		return time.Duration(value) * time.Hour, nil
+	case 'd':
+		return time.Duration(value) * 24 * time.Hour, nil
+	case 'w':
+		return time.Duration(value) * 7 * 24 * time.Hour, nil
+	default:
+		return 0, fmt.Errorf("invalid period unit: %s", s)
+	}
+}
+
+// loadChatHistory loads a token's history over the given period, hiding CEX
+// sources for chats that disabled them
+func loadChatHistory(chatID int64, token string, period time.Duration) ([]Rate, error) {
+	now := time.Now()
+	history, err := db.GetRateHistory(token, "", now.Add(-period), now)
+	if err != nil {
+		return nil, err
+	}
+
+	if shouldShowCEXRates(chatID) {
+		return history, nil
+	}
+
+	var filtered []Rate
+	for _, entry := range history {
+		if entry.Category != "CEX" {
+			filtered = append(filtered, entry)
+		}
+	}
+	return filtered, nil
+}
+
+// formatPeriod renders a duration in the same units accepted by parsePeriod
+func formatPeriod(d time.Duration) string {
+	if d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+	return fmt.Sprintf("%dh", d/time.Hour)
+}
+
+// summarizeHistory aggregates history entries per source, sorted by source.
+// Entries are expected in chronological order so the last one wins.
+func summarizeHistory(history []Rate) []HistorySummary {
+	summaries := make(map[string]*HistorySummary)
+	for _, entry := range history {
+		summary, exists := summaries[entry.Source]
+		if !exists {
+			summary = &HistorySummary{
+				Source:     entry.Source,
+				MinLending: entry.LendingRate,
+				MaxLending: entry.LendingRate,
+				MinBorrow:  entry.BorrowRate,
+				MaxBorrow:  entry.BorrowRate,
+			}
+			summaries[entry.Source] = summary
+		}
+
+		summary.Samples++
+		summary.MinLending = min(summary.MinLending, entry.LendingRate)
+		summary.MaxLending = max(summary.MaxLending, entry.LendingRate)
+		summary.MinBorrow = min(summary.MinBorrow, entry.BorrowRate)
+		summary.MaxBorrow = max(summary.MaxBorrow, entry.BorrowRate)
+		summary.AvgLending += entry.LendingRate
+		summary.AvgBorrow += entry.BorrowRate
+		summary.LastLending = entry.LendingRate
+		summary.LastBorrow = entry.BorrowRate
+	}
+
+	var result []HistorySummary
+	for _, summary := range summaries {
+		summary.AvgLending /= float64(summary.Samples)
+		summary.AvgBorrow /= float64(summary.Samples)
+		result = append(result, *summary)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Source < result[j].Source
+	})
+	return result
+}
+
+// formatHistory renders history summaries as a monospace Telegram message
+func formatHistory(token string, period time.Duration, kind RateKind, summaries []HistorySummary) string {
+	var message strings.Builder
+	message.WriteString(fmt.Sprintf("*%s history (%s, %s)*\n", token, formatPeriod(period), kind))
+	message.WriteString(fmt.Sprintf("`%-8s%6s%6s%6s%6s`\n", "", "min", "max", "avg", "last"))
+
+	for _, summary := range summaries {
+		message.WriteString(fmt.Sprintf("🏦 *%s* (%d samples)\n", summary.Source, summary.Samples))
+		message.WriteString(fmt.Sprintf("`%-8s%5.0f%%%5.0f%%%5.0f%%%5.0f%%`\n", "Lend",
+			summary.MinLending, summary.MaxLending, summary.AvgLending, summary.LastLending))
+		if summary.MaxBorrow > 0 {
+			message.WriteString(fmt.Sprintf("`%-8s%5.0f%%%5.0f%%%5.0f%%%5.0f%%`\n", "Borrow",
+				summary.MinBorrow, summary.MaxBorrow, summary.AvgBorrow, summary.LastBorrow))
+		}
+	}
+
+	return message.String()
+}
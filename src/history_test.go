@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePeriod(t *testing.T) {
+	tests := []struct {
+		input       string
+		want        time.Duration
+		expectError bool
+	}{
+		{"", defaultHistoryPeriod, false},
+		{"24h", 24 * time.Hour, false},
+		{"7d", 7 * 24 * time.Hour, false},
+		{"7D", 7 * 24 * time.Hour, false},
+		{"2w", 14 * 24 * time.Hour, false},
+		{"0d", 0, true},
+		{"d", 0, true},
+		{"7m", 0, true},
+		{"abc", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parsePeriod(tt.input)
+			if (err != nil) != tt.expectError {
+				t.Fatalf("parsePeriod(%q) error = %v, expectError %v", tt.input, err, tt.expectError)
+			}
+			if got != tt.want {
+				t.Errorf("parsePeriod(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummarizeHistory(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	history := []Rate{
+		{Source: "Neptune", Token: "USDT", LendingRate: 20, BorrowRate: 30, FetchedAt: base},
+		{Source: "OKX", Token: "USDT", LendingRate: 8, BorrowRate: 10, FetchedAt: base},
+		{Source: "Neptune", Token: "USDT", LendingRate: 40, BorrowRate: 50, FetchedAt: base.Add(time.Hour)},
+		{Source: "Neptune", Token: "USDT", LendingRate: 30, BorrowRate: 40, FetchedAt: base.Add(2 * time.Hour)},
+	}
+
+	summaries := summarizeHistory(history)
+	if len(summaries) != 2 {
+		t.Fatalf("summarizeHistory() got %d summaries, want 2", len(summaries))
+	}
+
+	neptune := summaries[0]
+	if neptune.Source != "Neptune" {
+		t.Fatalf("summarizeHistory() first source = %s, want Neptune", neptune.Source)
+	}
+	if neptune.Samples != 3 {
+		t.Errorf("Samples = %d, want 3", neptune.Samples)
+	}
+	if neptune.MinLending != 20 || neptune.MaxLending != 40 || neptune.AvgLending != 30 || neptune.LastLending != 30 {
+		t.Errorf("lending stats = %+v, want min 20 max 40 avg 30 last 30", neptune)
+	}
+	if neptune.MinBorrow != 30 || neptune.MaxBorrow != 50 || neptune.AvgBorrow != 40 || neptune.LastBorrow != 40 {
+		t.Errorf("borrow stats = %+v, want min 30 max 50 avg 40 last 40", neptune)
+	}
+
+	message := formatHistory("USDT", 7*24*time.Hour, RateKindAPR, summaries)
+	for _, want := range []string{"USDT history (7d, APR)", "Neptune", "OKX"} {
+		if !strings.Contains(message, want) {
+			t.Errorf("formatHistory() missing %q in:\n%s", want, message)
+		}
+	}
+}
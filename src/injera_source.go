@@ -1,100 +1,174 @@
 package main
 
 import (
-	"encoding/base64"
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
+	"math"
 	"strconv"
+	"time"
 )
 
+// injeraRedBank is Injera's lending (red bank) contract
+const injeraRedBank = "inj1dffuj4ud2fn7vhhw7dec6arx7tuyxd56srjwk4"
+
+// defaultInjectiveLCDs are public Injective REST endpoints, tried in order
+var defaultInjectiveLCDs = []string{
+	"https://inj24984.allnodes.me:1317/iAeAChGmajFpOeRk",
+	"https://sentry.lcd.injective.network",
+	"https://injective-rest.publicnode.com",
+}
+
+// injeraScalingFactor converts red bank scaled amounts to base units
+const injeraScalingFactor = 1e6
+
+// injeraPageSize is the number of markets requested per page
+const injeraPageSize = 10
+
 type InjeraSource struct {
-	APIURL   string
-	Category string
+	Client     *CosmWasmClient
+	Contract   string
+	Registry   *DenomRegistry
+	Category   string
+	Convention RateConvention
+
+	unknownDenoms
+}
+
+// InjeraQuery is a red bank query message
+type InjeraQuery struct {
+	Markets *InjeraMarketsQuery `json:"markets,omitempty"`
 }
 
-type InjeraResponse struct {
-	Data struct {
-		ReturnData []struct {
-			Success bool   `json:"success"`
-			Data    string `json:"data"`
-		} `json:"return_data"`
-	} `json:"data"`
+// InjeraMarketsQuery lists markets a page at a time, ordered by denom
+type InjeraMarketsQuery struct {
+	StartAfter string `json:"start_after,omitempty"`
+	Limit      int    `json:"limit,omitempty"`
 }
 
-type InjeraRates struct {
-	BorrowRate    string `json:"borrow_rate"`
-	LiquidityRate string `json:"liquidity_rate"`
-	// Add other fields if necessary
+// InjeraMarket is a red bank market. Rates are fractions, e.g. "0.05", and
+// totals are scaled amounts that the indexes convert to base units.
+type InjeraMarket struct {
+	Denom                 string `json:"denom"`
+	BorrowRate            string `json:"borrow_rate"`
+	LiquidityRate         string `json:"liquidity_rate"`
+	BorrowIndex           string `json:"borrow_index"`
+	LiquidityIndex        string `json:"liquidity_index"`
+	CollateralTotalScaled string `json:"collateral_total_scaled"`
+	DebtTotalScaled       string `json:"debt_total_scaled"`
 }
 
 func NewInjeraSource() *InjeraSource {
 	return &InjeraSource{
-		APIURL:   "https://inj24984.allnodes.me:1317/iAeAChGmajFpOeRk/cosmwasm/wasm/v1/contract/inj1578zx2zmp46l554zlw5jqq3nslth6ss04dv0ee/smart/ewogICJhZ2dyZWdhdGUiOiB7CiAgICAicXVlcmllcyI6IFsKICAgICAgewogICAgICAgICJhZGRyZXNzIjogImluajFkZmZ1ajR1ZDJmbjd2aGh3N2RlYzZhcng3dHV5eGQ1NnNyandrNCIsCiAgICAgICAgImRhdGEiOiAiZXlKdFlYSnJaWFFpT25zaVpHVnViMjBpT2lKd1pXZG5lVEI0WkVGRE1UZEdPVFU0UkRKbFpUVXlNMkV5TWpBMk1qQTJPVGswTlRrM1F6RXpSRGd6TVdWak55SjlmUT09IgogICAgICB9LAogICAgICB7CiAgICAgICAgImFkZHJlc3MiOiAiaW5qMXE1ZTZwbGVoMmg5ZDJxcjNtN2RybXhqN2tsZ3VnZzdweHZ1a3d0IiwKICAgICAgICAiZGF0YSI6ICJleUpoWTNScGRtVmZaVzFwYzNOcGIyNXpJanA3SW1OdmJHeGhkR1Z5WVd4ZlpHVnViMjBpT2lKd1pXZG5lVEI0WkVGRE1UZEdPVFU0UkRKbFpUVXlNMkV5TWpBMk1qQTJPVGswTlRrM1F6RXpSRGd6TVdWak55SjlmUT09IgogICAgICB9CiAgICBdCiAgfQp9",
-		Category: "DEX",
+		Client:     NewCosmWasmClient(defaultInjectiveLCDs...),
+		Contract:   injeraRedBank,
+		Registry:   defaultDenomRegistry(),
+		Category:   "DEX",
+		Convention: dailyAPR, // Assuming daily compounding
 	}
 }
 
-func (s *InjeraSource) FetchRates() ([]Rate, error) {
-	resp, err := http.Get(s.APIURL)
+func (s *InjeraSource) Name() string {
+	return "Injera"
+}
+
+// FetchRates reports every market listed by the red bank whose denom has a
+// known token. Markets that cannot be parsed are logged and skipped, unknown
+// denoms are recorded. If no listed market can be reported an error is
+// returned.
+func (s *InjeraSource) FetchRates(ctx context.Context) ([]Rate, error) {
+	markets, err := s.markets(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("error fetching Injera data: %v", err)
+		return nil, fmt.Errorf("error fetching Injera markets: %w", err)
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("error reading Injera response body: %v", err)
+	var rates []Rate
+	unknown := make(map[string]bool)
+	for _, market := range markets {
+		asset, ok := s.Registry.Lookup(market.Denom)
+		if !ok {
+			unknown[market.Denom] = true
+			continue
+		}
+
+		rate, err := s.marketRate(asset, market)
+		if err != nil {
+			log.Printf("Error parsing Injera %s market: %v", asset.Symbol, err)
+			continue
+		}
+		rates = append(rates, rate)
+	}
+	s.unknownDenoms.update("Injera", unknown)
+
+	if len(markets) > 0 && len(rates) == 0 {
+		return nil, fmt.Errorf("none of the %d Injera markets could be reported, %d have unknown denoms",
+			len(markets), len(unknown))
+	}
+	return rates, nil
+}
+
+// markets pages through the red bank's market list
+func (s *InjeraSource) markets(ctx context.Context) ([]InjeraMarket, error) {
+	var markets []InjeraMarket
+	query := InjeraMarketsQuery{Limit: injeraPageSize}
+	for {
+		var page []InjeraMarket
+		if err := s.Client.SmartQuery(ctx, s.Contract, InjeraQuery{Markets: &query}, &page); err != nil {
+			return nil, err
+		}
+		markets = append(markets, page...)
+		if len(page) < injeraPageSize || page[len(page)-1].Denom == query.StartAfter {
+			return markets, nil
+		}
+		query.StartAfter = page[len(page)-1].Denom
 	}
+}
 
-	var injeraResp InjeraResponse
-	err = json.Unmarshal(body, &injeraResp)
+// marketRate converts a red bank market into a Rate for the asset
+func (s *InjeraSource) marketRate(asset DenomAsset, market InjeraMarket) (Rate, error) {
+	borrowRate, err := strconv.ParseFloat(market.BorrowRate, 64)
+	if err != nil {
+		return Rate{}, fmt.Errorf("error parsing borrow_rate: %v", err)
+	}
+	liquidityRate, err := strconv.ParseFloat(market.LiquidityRate, 64)
 	if err != nil {
-		return nil, fmt.Errorf("error unmarshaling Injera response: %v", err)
+		return Rate{}, fmt.Errorf("error parsing liquidity_rate: %v", err)
 	}
 
-	var rates []Rate
-	for _, item := range injeraResp.Data.ReturnData {
-		if item.Success && item.Data != "" {
-			decodedData, err := base64.StdEncoding.DecodeString(item.Data)
-			if err != nil {
-				log.Printf("Error decoding Injera data: %v", err)
-				continue
-			}
-
-			var ratesData InjeraRates
-			err = json.Unmarshal(decodedData, &ratesData)
-			if err != nil {
-				// Ignore the error and skip this item
-				continue
-			}
-
-			// Convert rate strings to float64
-			borrowRate, err := strconv.ParseFloat(ratesData.BorrowRate, 64)
-			if err != nil {
-				log.Printf("Error parsing borrow_rate for Injera: %v", err)
-				continue
-			}
-
-			liquidityRate, err := strconv.ParseFloat(ratesData.LiquidityRate, 64)
-			if err != nil {
-				log.Printf("Error parsing liquidity_rate for Injera: %v", err)
-				continue
-			}
-
-			// Format the update as a Rate struct for USDT
-			rate := Rate{
-				Source:      "Injera",
-				Token:       "USDT",
-				BorrowRate:  borrowRate * 100,
-				LendingRate: liquidityRate * 100,
-				Category:    s.Category,
-			}
-			rates = append(rates, rate)
+	rate := Rate{
+		Source:         "Injera",
+		Token:          asset.Symbol,
+		BorrowRate:     borrowRate * 100,
+		LendingRate:    liquidityRate * 100,
+		Category:       s.Category,
+		FetchedAt:      time.Now(),
+		RawBorrowRate:  borrowRate * 100,
+		RawLendingRate: liquidityRate * 100,
+		SourceURL:      s.Client.Endpoint(),
+	}
+
+	// Totals are optional, older markets may not report them
+	supplied, supplyErr := scaledAmount(market.CollateralTotalScaled, market.LiquidityIndex)
+	borrowed, debtErr := scaledAmount(market.DebtTotalScaled, market.BorrowIndex)
+	if supplyErr == nil {
+		rate.TotalSupply = supplied / math.Pow10(asset.Decimals)
+		if debtErr == nil && supplied > 0 {
+			rate.Utilization = borrowed / supplied * 100
 		}
 	}
 
-	return rates, nil
+	return s.Convention.apply(rate), nil
+}
+
+// scaledAmount converts a red bank scaled total into base units
+func scaledAmount(scaled, index string) (float64, error) {
+	amount, err := strconv.ParseFloat(scaled, 64)
+	if err != nil {
+		return 0, err
+	}
+	factor, err := strconv.ParseFloat(index, 64)
+	if err != nil {
+		return 0, err
+	}
+	return amount * factor / injeraScalingFactor, nil
 }
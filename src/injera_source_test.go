@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestInjeraSource_FetchRates(t *testing.T) {
+	// Two pages of markets, the first full
+	pages := map[string]string{
+		`{"markets":{"limit":10}}`: `[
+			{"denom": "factory/unknown", "borrow_rate": "0.3", "liquidity_rate": "0.2"},
+			{"denom": "ibc/2CBC2EA121AE42563B08028466F37B600F2D7D4282342DE938283CC3FB2BC00E", "borrow_rate": "bad", "liquidity_rate": "0.05"},
+			{"denom": "inj", "borrow_rate": "0.04", "liquidity_rate": "0.01",
+			 "borrow_index": "1.0", "liquidity_index": "1.0",
+			 "collateral_total_scaled": "2000000000000000000000000000", "debt_total_scaled": "500000000000000000000000000"},
+			{"denom": "m1"}, {"denom": "m2"}, {"denom": "m3"}, {"denom": "m4"}, {"denom": "m5"}, {"denom": "m6"},
+			{"denom": "m7"}
+		]`,
+		`{"markets":{"start_after":"m7","limit":10}}`: `[
+			{"denom": "peggy0xdAC17F958D2ee523a2206206994597C13D831ec7", "borrow_rate": "0.12", "liquidity_rate": "0.08",
+			 "borrow_index": "1.2", "liquidity_index": "1.1",
+			 "collateral_total_scaled": "1000000000000000000", "debt_total_scaled": "500000000000000000"}
+		]`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, ok := pages[decodeSmartQuery(t, r)]
+		if !ok {
+			t.Errorf("unexpected query %s", decodeSmartQuery(t, r))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"data": ` + page + `}`))
+	}))
+	defer server.Close()
+
+	source := NewInjeraSource()
+	source.Client = NewCosmWasmClient(server.URL)
+
+	rates, err := source.FetchRates(context.Background())
+	if err != nil {
+		t.Fatalf("FetchRates() error = %v", err)
+	}
+
+	tests := []struct {
+		token       string
+		lending     float64
+		borrow      float64
+		utilization float64
+		totalSupply float64
+	}{
+		{"INJ", 1, 4, 25, 2000},
+		{"USDT", 8, 12, 500.0 * 1.2 / (1000 * 1.1) * 100, 1100000},
+	}
+	if len(rates) != len(tests) {
+		t.Fatalf("FetchRates() returned %d rates, want %d: %+v", len(rates), len(tests), rates)
+	}
+	for i, tt := range tests {
+		rate := rates[i]
+		if rate.Token != tt.token {
+			t.Errorf("rate %d token = %s, want %s", i, rate.Token, tt.token)
+		}
+		if math.Abs(rate.LendingRate-tt.lending) > 1e-9 || math.Abs(rate.BorrowRate-tt.borrow) > 1e-9 {
+			t.Errorf("%s rates = %.2f/%.2f, want %.2f/%.2f", tt.token, rate.LendingRate, rate.BorrowRate, tt.lending, tt.borrow)
+		}
+		if math.Abs(rate.Utilization-tt.utilization) > 1e-6 || math.Abs(rate.TotalSupply-tt.totalSupply) > 1e-6 {
+			t.Errorf("%s utilization/supply = %.2f/%.2f, want %.2f/%.2f",
+				tt.token, rate.Utilization, rate.TotalSupply, tt.utilization, tt.totalSupply)
+		}
+		if rate.Source != "Injera" || rate.Kind != RateKindAPR || !strings.HasPrefix(rate.SourceURL, server.URL) {
+			t.Errorf("%s rate = %+v, want an Injera APR from the test endpoint", tt.token, rate)
+		}
+	}
+
+	unknown := source.UnknownDenoms()
+	if len(unknown) != 8 || unknown[0] != "factory/unknown" {
+		t.Errorf("UnknownDenoms() = %v, want factory/unknown and m1-m7", unknown)
+	}
+}
+
+func TestInjeraSource_FetchRatesError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	source := NewInjeraSource()
+	source.Client = NewCosmWasmClient(server.URL)
+	if _, err := source.FetchRates(context.Background()); err == nil {
+		t.Error("FetchRates() should fail when the markets cannot be listed")
+	}
+}
+
+func TestInjeraSource_NoReportableMarkets(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": [{"denom": "factory/unknown", "borrow_rate": "0.3", "liquidity_rate": "0.2"},
+			{"denom": "inj", "borrow_rate": "bad", "liquidity_rate": "0.01"}]}`))
+	}))
+	defer server.Close()
+
+	source := NewInjeraSource()
+	source.Client = NewCosmWasmClient(server.URL)
+	if rates, err := source.FetchRates(context.Background()); err == nil {
+		t.Errorf("FetchRates() = %+v, want an error when no market can be reported", rates)
+	}
+	if unknown := source.UnknownDenoms(); len(unknown) != 1 {
+		t.Errorf("UnknownDenoms() = %v, want factory/unknown", unknown)
+	}
+}
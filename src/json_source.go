@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JSONSourceConfig declares a venue whose rates can be read from a single
+// HTTP endpoint returning JSON. Paths are dot separated object keys or array
+// indexes, e.g. "data.list" or "result.0.apy".
+type JSONSourceConfig struct {
+	Name             string            `yaml:"name"`
+	Category         string            `yaml:"category"` // CEX or DEX
+	URL              string            `yaml:"url"`
+	Method           string            `yaml:"method"` // GET (default) or POST
+	Body             string            `yaml:"body"`
+	Headers          map[string]string `yaml:"headers"`
+	ListPath         string            `yaml:"list_path"` // Path to the list of markets, empty for the root
+	TokenField       string            `yaml:"token_field"`
+	LendingField     string            `yaml:"lending_field"`
+	BorrowField      string            `yaml:"borrow_field"`  // Optional
+	LendingScale     float64           `yaml:"lending_scale"` // Multiplier to percent, default 1
+	BorrowScale      float64           `yaml:"borrow_scale"`  // Multiplier to percent, default 1
+	Kind             RateKind          `yaml:"kind"`          // APR or APY, default APY
+	CompoundsPerYear int               `yaml:"compounds_per_year"`
+	Tokens           []string          `yaml:"tokens"` // Only report these tokens, empty for all
+}
+
+// validate checks required fields and fills in defaults
+func (c *JSONSourceConfig) validate() error {
+	if c.Name == "" || c.URL == "" || c.TokenField == "" || c.LendingField == "" {
+		return fmt.Errorf("json source %q needs name, url, token_field and lending_field", c.Name)
+	}
+
+	c.Method = strings.ToUpper(c.Method)
+	if c.Method == "" {
+		c.Method = http.MethodGet
+	}
+	if c.Method != http.MethodGet && c.Method != http.MethodPost {
+		return fmt.Errorf("json source %s: unsupported method %q", c.Name, c.Method)
+	}
+
+	if c.Kind == "" {
+		c.Kind = RateKindAPY
+	}
+	kind, err := parseRateKind(string(c.Kind))
+	if err != nil {
+		return fmt.Errorf("json source %s: %w", c.Name, err)
+	}
+	c.Kind = kind
+	if c.CompoundsPerYear == 0 {
+		c.CompoundsPerYear = 365
+	}
+
+	if c.LendingScale == 0 {
+		c.LendingScale = 1
+	}
+	if c.BorrowScale == 0 {
+		c.BorrowScale = 1
+	}
+	if c.Category == "" {
+		c.Category = "DEX"
+	}
+	c.Category = strings.ToUpper(c.Category)
+	for i, token := range c.Tokens {
+		c.Tokens[i] = strings.ToUpper(token)
+	}
+	return nil
+}
+
+// JSONSource is a RateSource driven entirely by a JSONSourceConfig
+type JSONSource struct {
+	Config     JSONSourceConfig
+	Convention RateConvention
+}
+
+func NewJSONSource(cfg JSONSourceConfig) (*JSONSource, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return &JSONSource{
+		Config:     cfg,
+		Convention: RateConvention{Kind: cfg.Kind, CompoundsPerYear: cfg.CompoundsPerYear},
+	}, nil
+}
+
+func (s *JSONSource) Name() string {
+	return s.Config.Name
+}
+
+func (s *JSONSource) FetchRates(ctx context.Context) ([]Rate, error) {
+	var body io.Reader
+	if s.Config.Body != "" {
+		body = strings.NewReader(s.Config.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, s.Config.Method, s.Config.URL, body)
+	if err != nil {
+		return nil, fmt.Errorf("error creating %s request: %v", s.Name(), err)
+	}
+	if s.Config.Method == http.MethodPost && s.Config.Body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for key, value := range s.Config.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching %s data: %w", s.Name(), err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponseStatus(resp); err != nil {
+		return nil, fmt.Errorf("error fetching %s data: %w", s.Name(), err)
+	}
+
+	var data interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("error unmarshaling %s response: %w", s.Name(), err)
+	}
+
+	return s.parseRates(data, time.Now())
+}
+
+// parseRates walks the decoded response and builds a rate per list entry.
+// Entries without a token or lending rate are skipped and counted; if every
+// entry is skipped the field paths are most likely wrong and an error is
+// returned.
+func (s *JSONSource) parseRates(data interface{}, fetchedAt time.Time) ([]Rate, error) {
+	list, err := lookupPath(data, s.Config.ListPath)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", s.Name(), err)
+	}
+	items, ok := list.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: %q is not a list", s.Name(), s.Config.ListPath)
+	}
+
+	var rates []Rate
+	var skipped int
+	var skipErr error
+	skip := func(err error) {
+		skipped++
+		if skipErr == nil {
+			skipErr = err
+		}
+	}
+	for _, item := range items {
+		tokenValue, err := lookupPath(item, s.Config.TokenField)
+		if err != nil {
+			skip(err)
+			continue
+		}
+		token, ok := tokenValue.(string)
+		if !ok || token == "" {
+			skip(fmt.Errorf("%q is not a token symbol", s.Config.TokenField))
+			continue
+		}
+		token = strings.ToUpper(token)
+		if len(s.Config.Tokens) > 0 && !slices.Contains(s.Config.Tokens, token) {
+			continue
+		}
+
+		lending, err := lookupNumber(item, s.Config.LendingField)
+		if err != nil {
+			skip(err)
+			continue
+		}
+		rate := Rate{
+			Source:         s.Name(),
+			Token:          token,
+			LendingRate:    lending * s.Config.LendingScale,
+			RawLendingRate: lending * s.Config.LendingScale,
+			Category:       s.Config.Category,
+			FetchedAt:      fetchedAt,
+			SourceURL:      s.Config.URL,
+		}
+		if s.Config.BorrowField != "" {
+			if borrow, err := lookupNumber(item, s.Config.BorrowField); err == nil {
+				rate.BorrowRate = borrow * s.Config.BorrowScale
+				rate.RawBorrowRate = rate.BorrowRate
+			}
+		}
+		rates = append(rates, s.Convention.apply(rate))
+	}
+
+	if skipped > 0 {
+		if len(rates) == 0 {
+			return nil, fmt.Errorf("%s: skipped all %d entries: %w", s.Name(), skipped, skipErr)
+		}
+		log.Printf("%s: skipped %d of %d entries: %v", s.Name(), skipped, len(items), skipErr)
+	}
+	return rates, nil
+}
+
+// lookupPath follows a dot separated path of object keys and array indexes
+func lookupPath(value interface{}, path string) (interface{}, error) {
+	if path == "" {
+		return value, nil
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch node := value.(type) {
+		case map[string]interface{}:
+			next, exists := node[key]
+			if !exists {
+				return nil, fmt.Errorf("missing field %q in path %q", key, path)
+			}
+			value = next
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, fmt.Errorf("invalid index %q in path %q", key, path)
+			}
+			value = node[index]
+		default:
+			return nil, fmt.Errorf("cannot descend into %q in path %q", key, path)
+		}
+	}
+	return value, nil
+}
+
+// lookupNumber reads a number at a path, accepting numeric strings
+func lookupNumber(value interface{}, path string) (float64, error) {
+	value, err := lookupPath(value, path)
+	if err != nil {
+		return 0, err
+	}
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case string:
+		return strconv.ParseFloat(v, 64)
+	default:
+		return 0, fmt.Errorf("%q is not a number", path)
+	}
+}
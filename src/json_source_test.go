@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJSONSource_FetchRates(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method != http.MethodPost || string(body) != `{"chain":"injective"}` {
+			t.Errorf("request = %s %s, want POST with the configured body", r.Method, body)
+		}
+		if r.Header.Get("X-Api-Key") != "secret" {
+			t.Errorf("X-Api-Key header = %q, want secret", r.Header.Get("X-Api-Key"))
+		}
+		w.Write([]byte(`{"data": {"markets": [
+			{"asset": {"symbol": "usdt"}, "supply": "0.08", "borrow": 0.12},
+			{"asset": {"symbol": "USDC"}, "supply": 0.05},
+			{"asset": {"symbol": "INJ"}, "supply": 0.02, "borrow": 0.04},
+			{"asset": {}, "supply": 0.5},
+			{"asset": {"symbol": "TIA"}, "supply": "n/a"}
+		]}}`))
+	}))
+	defer server.Close()
+
+	source, err := NewJSONSource(JSONSourceConfig{
+		Name:         "Example",
+		URL:          server.URL,
+		Method:       "post",
+		Body:         `{"chain":"injective"}`,
+		Headers:      map[string]string{"X-Api-Key": "secret"},
+		ListPath:     "data.markets",
+		TokenField:   "asset.symbol",
+		LendingField: "supply",
+		BorrowField:  "borrow",
+		LendingScale: 100,
+		BorrowScale:  100,
+		Tokens:       []string{"usdt", "USDC", "TIA"},
+	})
+	if err != nil {
+		t.Fatalf("NewJSONSource() error = %v", err)
+	}
+
+	rates, err := source.FetchRates(context.Background())
+	if err != nil {
+		t.Fatalf("FetchRates() error = %v", err)
+	}
+	if len(rates) != 2 {
+		t.Fatalf("FetchRates() returned %d rates, want 2: %+v", len(rates), rates)
+	}
+
+	tests := []struct {
+		token   string
+		lending float64
+		borrow  float64
+	}{
+		{"USDT", 8, 12},
+		{"USDC", 5, 0},
+	}
+	for i, tt := range tests {
+		rate := rates[i]
+		if rate.Token != tt.token || math.Abs(rate.LendingRate-tt.lending) > 1e-9 || math.Abs(rate.BorrowRate-tt.borrow) > 1e-9 {
+			t.Errorf("rate %d = %s %.2f/%.2f, want %s %.2f/%.2f",
+				i, rate.Token, rate.LendingRate, rate.BorrowRate, tt.token, tt.lending, tt.borrow)
+		}
+		if rate.Source != "Example" || rate.Category != "DEX" || rate.Kind != RateKindAPY || rate.CompoundsPerYear != 365 {
+			t.Errorf("rate %d = %+v, want Example DEX APY compounded daily", i, rate)
+		}
+	}
+}
+
+func TestJSONSource_ParseRatesErrors(t *testing.T) {
+	source, err := NewJSONSource(JSONSourceConfig{
+		Name:         "Example",
+		URL:          "http://localhost",
+		ListPath:     "data",
+		TokenField:   "symbol",
+		LendingField: "apy",
+	})
+	if err != nil {
+		t.Fatalf("NewJSONSource() error = %v", err)
+	}
+
+	tests := map[string]interface{}{
+		"missing list": map[string]interface{}{"result": []interface{}{}},
+		"not a list":   map[string]interface{}{"data": "USDT"},
+		"bad field path": map[string]interface{}{"data": []interface{}{
+			map[string]interface{}{"symbol": "USDT", "supplyApy": 5.2},
+			map[string]interface{}{"symbol": "USDC", "supplyApy": 4.8},
+		}},
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := source.parseRates(data, time.Now()); err == nil {
+				t.Errorf("parseRates(%v) should fail", data)
+			}
+		})
+	}
+
+	// Entries that do not parse are skipped as long as some do
+	partial := map[string]interface{}{"data": []interface{}{
+		map[string]interface{}{"symbol": "USDT", "apy": 5.2},
+		map[string]interface{}{"symbol": "USDC"},
+	}}
+	rates, err := source.parseRates(partial, time.Now())
+	if err != nil || len(rates) != 1 || rates[0].Token != "USDT" {
+		t.Errorf("parseRates() = %+v, %v, want only USDT", rates, err)
+	}
+
+	// An empty list is not an error
+	if rates, err := source.parseRates(map[string]interface{}{"data": []interface{}{}}, time.Now()); err != nil || len(rates) != 0 {
+		t.Errorf("parseRates() for empty list = %+v, %v, want no rates and no error", rates, err)
+	}
+}
+
+func TestJSONSourceConfig_Validate(t *testing.T) {
+	valid := JSONSourceConfig{Name: "Example", URL: "http://localhost", TokenField: "symbol", LendingField: "apy"}
+
+	tests := []struct {
+		name    string
+		modify  func(*JSONSourceConfig)
+		wantErr bool
+	}{
+		{"valid", func(c *JSONSourceConfig) {}, false},
+		{"missing url", func(c *JSONSourceConfig) { c.URL = "" }, true},
+		{"missing lending field", func(c *JSONSourceConfig) { c.LendingField = "" }, true},
+		{"unsupported method", func(c *JSONSourceConfig) { c.Method = "PUT" }, true},
+		{"unknown kind", func(c *JSONSourceConfig) { c.Kind = "APX" }, true},
+		{"apr", func(c *JSONSourceConfig) { c.Kind = "apr" }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid
+			tt.modify(&cfg)
+			if err := cfg.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLookupPath(t *testing.T) {
+	data := map[string]interface{}{
+		"result": []interface{}{
+			map[string]interface{}{"apy": 1.5, "name": "USDT"},
+		},
+	}
+
+	tests := []struct {
+		path    string
+		want    interface{}
+		wantErr bool
+	}{
+		{"result.0.apy", 1.5, false},
+		{"result.0.name", "USDT", false},
+		{"result.1.apy", nil, true},
+		{"result.x", nil, true},
+		{"result.0.apy.value", nil, true},
+		{"missing", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := lookupPath(data, tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("lookupPath(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("lookupPath(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_JSONSources(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeConfig(t, path, `
+sources:
+  json:
+    - name: Example
+      url: https://api.example.com/markets
+      token_field: symbol
+      lending_field: apy
+`)
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if got := cfg.Sources.JSON[0]; got.Method != http.MethodGet || got.LendingScale != 1 {
+		t.Errorf("loadConfig() json source = %+v, want defaults filled in", got)
+	}
+
+	found := false
+	for _, source := range buildSources(cfg.Sources) {
+		found = found || source.Name() == "Example"
+	}
+	if !found {
+		t.Error("buildSources() did not include the JSON source")
+	}
+
+	writeConfig(t, path, `
+sources:
+  json:
+    - {name: Binance, url: "http://localhost", token_field: symbol, lending_field: apy}
+`)
+	if _, err := loadConfig(path); err == nil {
+		t.Error("loadConfig() should reject a JSON source named after a built-in source")
+	}
+}
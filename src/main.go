@@ -1,12 +1,16 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"math"
+	"net/http"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -18,7 +22,18 @@ import (
 
 // RateSource defines the interface for rate providers
 type RateSource interface {
-	FetchRates() ([]Rate, error)
+	Name() string
+	FetchRates(ctx context.Context) ([]Rate, error)
+}
+
+// SourceError records a source that failed during a fetch
+type SourceError struct {
+	Source string
+	Err    error
+}
+
+func (e SourceError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Source, e.Err)
 }
 
 // Global storage for latest rates
@@ -33,10 +48,25 @@ var (
 		"USDT":  30.0,
 		"FDUSD": 30.0,
 	}
+	borrowThresholds    = map[string]BorrowThreshold{} // Default borrow ceilings/floors per token
 	db                  *Database
 	userPreferences     = make(map[int64]bool)             // Store user preferences for CEX rates
 	previousRates       = make(map[string]map[string]Rate) // token -> source -> rate
 	rateChangeThreshold = 5.0                              // 5% change threshold
+	rateDropThreshold   = 50.0                             // 50% drop triggers a drop alert
+	alertHysteresis     = 2.0                              // Points a rate must move back past a rule to re-arm it
+	alertCooldown       = time.Hour                        // Minimum time between repeats of the same alert
+	historyRetention    = 90 * 24 * time.Hour              // How long rate history is kept
+	fetchTimeout        = 45 * time.Second                 // Deadline for a whole fetch across sources
+	sourceTimeout       = 20 * time.Second                 // Deadline for a single source
+	httpClient          = &http.Client{Timeout: sourceTimeout}
+	lastGood            = make(map[string][]Rate) // source -> last successful rates
+	lastGoodMutex       sync.Mutex
+	lastGoodMaxAge      = 24 * time.Hour // Older last known good rates are no longer served
+	sourceHealth        = NewHealthRegistry()
+	sourceMonitor       = NewSourceMonitor()
+	sourceDownAfter     = 3             // Consecutive failed fetches before a source is reported down
+	sourceStaleAfter    = 6 * time.Hour // How long identical rates are accepted before a source is reported stale
 )
 
 // updateLatestRates updates the global rates storage thread-safely
@@ -54,34 +84,139 @@ func getLatestRates() []Rate {
 	return latestRates
 }
 
+// rememberRates stores a source's latest successful rates
+func rememberRates(source string, rates []Rate) {
+	lastGoodMutex.Lock()
+	defer lastGoodMutex.Unlock()
+	lastGood[source] = append([]Rate(nil), rates...)
+}
+
+// rememberPartialRates stores the rates of a partially failed fetch, keeping
+// the source's last successful rates for the tokens it did not return. The
+// kept rates younger than lastGoodMaxAge are returned marked as stale.
+func rememberPartialRates(source string, rates []Rate, now time.Time) []Rate {
+	lastGoodMutex.Lock()
+	defer lastGoodMutex.Unlock()
+
+	fetched := make(map[string]bool, len(rates))
+	for _, rate := range rates {
+		fetched[rate.Token] = true
+	}
+
+	kept := append([]Rate(nil), rates...)
+	var stale []Rate
+	for _, rate := range lastGood[source] {
+		if fetched[rate.Token] || now.Sub(rate.FetchedAt) > lastGoodMaxAge {
+			continue
+		}
+		kept = append(kept, rate)
+		rate.Stale = true
+		stale = append(stale, rate)
+	}
+	lastGood[source] = kept
+	return stale
+}
+
+// lastGoodRates returns a copy of a source's last successful rates marked as
+// stale, or nil if there are none younger than lastGoodMaxAge
+func lastGoodRates(source string, now time.Time) []Rate {
+	lastGoodMutex.Lock()
+	defer lastGoodMutex.Unlock()
+
+	var stale []Rate
+	for _, rate := range lastGood[source] {
+		if now.Sub(rate.FetchedAt) > lastGoodMaxAge {
+			continue
+		}
+		rate.Stale = true
+		stale = append(stale, rate)
+	}
+	return stale
+}
+
+// freshRates filters out stale rates
+func freshRates(rates []Rate) []Rate {
+	var fresh []Rate
+	for _, rate := range rates {
+		if !rate.Stale {
+			fresh = append(fresh, rate)
+		}
+	}
+	return fresh
+}
+
 // isCacheValid checks if the cache is valid
 func isCacheValid() bool {
 	ratesMutex.RLock()
 	defer ratesMutex.RUnlock()
+	configMutex.RLock()
+	defer configMutex.RUnlock()
 	return !lastFetchTime.IsZero() && time.Since(lastFetchTime) < cacheDuration
 }
 
-// fetchRates fetches rates from multiple sources
-func fetchRates(sources ...RateSource) ([]Rate, error) {
+// fetchRates fetches rates from multiple sources concurrently. Each source
+// gets its own timeout; rates from the sources that succeeded are returned
+// together with an error for each source that failed. Sources that failed
+// entirely or in part are filled in with their last known good rates.
+func fetchRates(ctx context.Context, sources ...RateSource) ([]Rate, []SourceError, error) {
 	log.Printf("Fetching rates from %d sources...", len(sources))
-	var allRates []Rate
-	var errors []error
 
-	for _, source := range sources {
-		rates, err := source.FetchRates()
-		if err != nil {
-			log.Printf("Error fetching rates from %T: %v", source, err)
-			errors = append(errors, err)
-			continue
+	type sourceResult struct {
+		rates   []Rate
+		err     error
+		latency time.Duration
+	}
+	results := make([]sourceResult, len(sources))
+
+	var wg sync.WaitGroup
+	for i, source := range sources {
+		wg.Add(1)
+		go func(i int, source RateSource) {
+			defer wg.Done()
+			sourceCtx, cancel := context.WithTimeout(ctx, sourceTimeout)
+			defer cancel()
+			start := time.Now()
+			rates, err := source.FetchRates(sourceCtx)
+			results[i] = sourceResult{rates: rates, err: err, latency: time.Since(start)}
+
+			// Partial fetches count as degraded successes with the rates they got
+			var partial *PartialError
+			if errors.As(err, &partial) {
+				rates = partial.Rates
+			}
+			sourceHealth.Record(source, rates, results[i].latency, err, time.Now())
+		}(i, source)
+	}
+	wg.Wait()
+
+	var allRates []Rate
+	var sourceErrors []SourceError
+	fetchedAt := time.Now()
+	for i, result := range results {
+		source := sources[i]
+		fetched := result.rates
+		var partial *PartialError
+		if result.err != nil {
+			log.Printf("Error fetching rates from %s: %v", source.Name(), result.err)
+			sourceErrors = append(sourceErrors, SourceError{Source: source.Name(), Err: result.err})
+
+			if !errors.As(result.err, &partial) {
+				// Keep serving the source's last successful rates, marked as stale
+				if stale := lastGoodRates(source.Name(), fetchedAt); len(stale) > 0 {
+					log.Printf("Serving %d stale rates from %s", len(stale), source.Name())
+					allRates = append(allRates, stale...)
+				}
+				continue
+			}
+			fetched = partial.Rates
 		}
 
-		// Convert APR to APY for Neptune and Injera rates
+		// Store rates in one kind so thresholds, alerts and history compare
+		// like with like across sources
+		rates := normalizeRates(fetched, defaultRateKind)
 		for i := range rates {
-			switch rates[i].Source {
-			case "Neptune", "Injera":
-				// Assuming daily compounding (365 times per year)
-				rates[i].LendingRate = convertAPRtoAPY(rates[i].LendingRate, 365)
-				rates[i].BorrowRate = convertAPRtoAPY(rates[i].BorrowRate, 365)
+			if rates[i].FetchedAt.IsZero() {
+				rates[i].FetchedAt = fetchedAt
 			}
 		}
 
@@ -91,32 +226,50 @@ func fetchRates(sources ...RateSource) ([]Rate, error) {
 				rate.Source, rate.Token, rate.LendingRate, rate.BorrowRate)
 		}
 
+		if partial != nil {
+			if stale := rememberPartialRates(source.Name(), rates, fetchedAt); len(stale) > 0 {
+				log.Printf("Serving %d stale rates from %s", len(stale), source.Name())
+				allRates = append(allRates, stale...)
+			}
+		} else {
+			rememberRates(source.Name(), rates)
+		}
 		allRates = append(allRates, rates...)
 	}
 
-	if len(allRates) == 0 && len(errors) > 0 {
-		return nil, fmt.Errorf("all sources failed to fetch rates")
+	if len(allRates) == 0 && len(sourceErrors) > 0 {
+		return nil, sourceErrors, fmt.Errorf("all sources failed to fetch rates")
 	}
 
-	log.Printf("Successfully fetched %d rates total", len(allRates))
+	log.Printf("Successfully fetched %d rates total, %d sources failed", len(allRates), len(sourceErrors))
 	updateLatestRates(allRates)
-	return allRates, nil
+	return allRates, sourceErrors, nil
 }
 
 // getRatesWithCache fetches rates with caching
-func getRatesWithCache(sources ...RateSource) ([]Rate, error) {
+func getRatesWithCache(ctx context.Context, sources ...RateSource) ([]Rate, error) {
 	if isCacheValid() {
 		return getLatestRates(), nil
 	}
-	return fetchRates(sources...)
+	rates, _, err := fetchRates(ctx, sources...)
+	return rates, err
 }
 
 var commandHelp = map[string]string{
-	"/start": "Subscribe to rate notifications",
-	"/stop":  "Unsubscribe from rate notifications",
-	"/rate":  "Show current rates for all tokens\nUsage: /rate [token]\nExample: /rate USDT",
-	"/help":  "Show this help message",
-	"/cex":   "Toggle visibility of CEX (Centralized Exchange) rates",
+	"/start":     "Subscribe to rate notifications\nUsage: /start [tokens...]\nExample: /start USDT USDC",
+	"/stop":      "Unsubscribe from rate notifications",
+	"/rate":      "Show current rates for all tokens, or one token with its deposit tiers\nUsage: /rate [token]\nExample: /rate USDT",
+	"/help":      "Show this help message",
+	"/cex":       "Toggle visibility of CEX (Centralized Exchange) rates",
+	"/history":   "Show min/max/avg/last rates per source over a period\nUsage: /history <token> [period]\nExample: /history USDT 7d",
+	"/threshold": "Show or set your lending and borrow rate alert thresholds\nUsage: /threshold [token] [percent|reset], /threshold <token> borrow <above|below> <percent>\nExample: /threshold USDT 25",
+	"/watch":     "Only get notifications for the given tokens, or for all tokens again\nUsage: /watch [tokens...|all]\nExample: /watch USDT USDC",
+	"/unwatch":   "Stop notifications for the given tokens\nUsage: /unwatch <tokens...>\nExample: /unwatch TIA",
+	"/alert":     "Manage custom alert rules (in addition to /threshold)\nUsage: /alert add [token] <lend|borrow> <op> <percent> [source=name] [category=CEX|DEX], /alert list, /alert rm <id>\nExample: /alert add USDT lend > 25 source=Neptune",
+	"/chart":     "Draw a chart of a token's rates per source\nUsage: /chart <token> [period] [borrow]\nExample: /chart USDT 7d borrow",
+	"/display":   "Show rates as APR or APY (alerts and thresholds always use APY)\nUsage: /display <apr|apy>\nExample: /display apr",
+	"/yield":     "Rank venues by the effective APY and interest a deposit would earn, taking deposit tiers into account\nUsage: /yield <token> <amount>\nExample: /yield USDT 250k",
+	"/sources":   "Show the status of each rate source: last success, errors, latency and rate count",
 }
 
 func getHelpMessage() string {
@@ -152,12 +305,32 @@ func shouldShowCEXRates(chatID int64) bool {
 	return preference
 }
 
+// getChatRateKind returns whether a chat wants rates shown as APR or APY
+func getChatRateKind(chatID int64) RateKind {
+	kind, err := db.GetRateKind(chatID)
+	if err != nil {
+		log.Printf("Error loading rate display preference for chat %d: %v", chatID, err)
+	}
+	return kind
+}
+
 func hasSignificantChange(oldRate, newRate Rate) bool {
-	if oldRate.LendingRate == 0 {
+	return significantChange(oldRate.LendingRate, newRate.LendingRate)
+}
+
+func hasSignificantBorrowChange(oldRate, newRate Rate) bool {
+	return significantChange(oldRate.BorrowRate, newRate.BorrowRate)
+}
+
+// significantChange reports whether a value moved by at least rateChangeThreshold percent
+func significantChange(oldValue, newValue float64) bool {
+	if oldValue == 0 {
 		return true // First time seeing this rate
 	}
 
-	percentChange := ((newRate.LendingRate - oldRate.LendingRate) / oldRate.LendingRate) * 100
+	percentChange := ((newValue - oldValue) / oldValue) * 100
+	configMutex.RLock()
+	defer configMutex.RUnlock()
 	return math.Abs(percentChange) >= rateChangeThreshold
 }
 
@@ -220,97 +393,92 @@ func main() {
 		log.Fatal("Failed to load subscribers:", err)
 	}
 
-	// Initialize sources
-	okxSource := NewOKXSource()
-	neptuneSource := NewNeptuneSource()
-	injeraSource := NewInjeraSource()
-	binanceSource := NewBinanceSimpleEarnSource()
-	bybitSource := NewBybitSource()
-
-	// Sources are already initialized with their categories in their respective New functions
-	sources := []RateSource{okxSource, neptuneSource, injeraSource, binanceSource, bybitSource}
+	// Load settings and sources from the config file, if there is one
+	configPath := getEnv("CONFIG_PATH", filepath.Join("data", "config.yaml"))
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Fatalf("Failed to load config: %v", err)
+		}
+		log.Printf("No config file at %s, using built-in defaults", configPath)
+		cfg = builtinConfig
+	}
+	applyConfig(cfg)
+	reloadSources(cfg.Sources)
 
 	// Function to fetch and process rates
 	cronFetchRates := func() {
-		rates, err := fetchRates(sources...)
+		ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+		defer cancel()
+
+		rates, _, err := fetchRates(ctx, currentSources()...)
+
+		// Tell subscribers about sources they display that went down, froze or
+		// recovered
+		if changes := sourceMonitor.Update(sourceHealth.Snapshot(), time.Now()); len(changes) > 0 {
+			for chatID := range activeChatIDs {
+				shown := sourceChangesFor(changes, loadChatSettings(chatID))
+				if len(shown) == 0 {
+					continue
+				}
+				msg := tgbotapi.NewMessage(chatID, formatSourceStatusChanges(shown, time.Now()))
+				msg.ParseMode = "markdown"
+				sendTelegramMessage(bot, msg)
+			}
+		}
+
 		if err != nil {
 			log.Printf("Error fetching rates: %v", err)
 			return
 		}
 
-		// Filter rates based on lending rate thresholds and significant changes
-		filteredRates := []Rate{}
-		for _, rate := range rates {
-			threshold, exists := lendingThresholds[rate.Token]
-			if !exists {
-				continue
-			}
+		// Stale rates were already recorded and alerted on when they were fresh
+		fresh := freshRates(rates)
 
-			// Check if rate exceeds threshold and has significant change
-			if rate.LendingRate >= threshold {
-				prevRate, hasPrevious := previousRates[rate.Token][rate.Source]
-				if !hasPrevious || hasSignificantChange(prevRate, rate) {
-					filteredRates = append(filteredRates, rate)
-				}
-			}
+		// Record this snapshot in the rate history
+		if err := db.SaveRateHistory(fresh, time.Now()); err != nil {
+			log.Printf("Error saving rate history: %v", err)
 		}
 
-		// Update previous rates after filtering
-		updatePreviousRates(rates)
+		// Evaluate each chat's alert rules against the previous run
+		notified := 0
+		for chatID := range activeChatIDs {
+			settings := loadChatSettings(chatID)
 
-		if len(filteredRates) > 0 {
-			// Group rates by token
-			ratesByToken := make(map[string][]Rate)
-			tokensWithHighRates := make(map[string]bool)
-
-			// First, identify tokens with high rates
-			for _, rate := range filteredRates {
-				tokensWithHighRates[rate.Token] = true
+			states, err := db.GetAlertStates(chatID)
+			if err != nil {
+				log.Printf("Error loading alert state for chat %d: %v", chatID, err)
+				continue
 			}
+			tracker := newAlertTracker(chatID, states, time.Now())
 
-			// Then, collect all rates for those tokens
-			for _, rate := range rates {
-				if tokensWithHighRates[rate.Token] {
-					ratesByToken[rate.Token] = append(ratesByToken[rate.Token], rate)
-				}
+			matches := evaluateAlertRules(fresh, previousRates, settings, tracker)
+			if len(matches) > 0 {
+				msg := tgbotapi.NewMessage(chatID, formatRateAlert(matches, rates, settings))
+				msg.ParseMode = "markdown"
+				sendTelegramMessage(bot, msg)
+				notified++
 			}
 
-			// Send notification to all active chat IDs
-			for chatID := range activeChatIDs {
-				var message strings.Builder
-
-				// Build message based on user preferences
-				for token := range tokensWithHighRates {
-					rates := ratesByToken[token]
-
-					// Filter rates based on user preferences
-					for _, rate := range rates {
-						if !shouldShowCEXRates(chatID) && rate.Category == "CEX" {
-							continue
-						}
-					}
-
-					message.WriteString(fmt.Sprintf("🪙 *%s*\n", token))
-
-					// Sort rates by source for consistent ordering
-					sort.Slice(rates, func(i, j int) bool {
-						return rates[i].Source < rates[j].Source
-					})
-
-					for _, rate := range rates {
-						message.WriteString(formatRate(rate, lendingThresholds[token]))
-						message.WriteString("\n")
-					}
-					message.WriteString("\n")
-				}
-
-				msg := tgbotapi.NewMessage(chatID, message.String())
+			drops := detectRateDrops(fresh, previousRates, settings, tracker)
+			if len(drops) > 0 {
+				msg := tgbotapi.NewMessage(chatID, formatDropAlert(drops, settings.rateKind))
 				msg.ParseMode = "markdown"
 				sendTelegramMessage(bot, msg)
+				notified++
+			}
+
+			if err := db.SaveAlertStates(tracker.changedStates()); err != nil {
+				log.Printf("Error saving alert state for chat %d: %v", chatID, err)
 			}
-		} else {
-			// Log rates that were checked but didn't meet the significance threshold
-			log.Println("No rates met the significance threshold")
+		}
+
+		// Update previous rates after evaluating alerts
+		updatePreviousRates(fresh)
+
+		if notified == 0 {
+			// Log rates that were checked but didn't trigger any alert rule
+			log.Println("No rates triggered an alert")
 		}
 	}
 
@@ -321,13 +489,47 @@ func main() {
 	// Start a goroutine to handle rate checking and notifications
 	c := cron.New()
 
-	// Schedule rate fetching for the 59th minute of every hour
-	_, err = c.AddFunc("*/2 * * * *", cronFetchRates)
+	// Schedule rate fetching
+	fetchJob, err := c.AddFunc(cfg.Schedule, cronFetchRates)
 
 	if err != nil {
 		log.Fatal("Error setting up cron job:", err)
 	}
 
+	// Apply config changes without restarting
+	schedule := cfg.Schedule
+	watchConfig(context.Background(), configPath, 30*time.Second, func(cfg Config) {
+		applyConfig(cfg)
+		for _, name := range reloadSources(cfg.Sources) {
+			log.Printf("Source %s was removed from the config", name)
+			sourceHealth.Remove(name)
+			sourceMonitor.Remove(name)
+		}
+		if cfg.Schedule != schedule {
+			job, err := c.AddFunc(cfg.Schedule, cronFetchRates)
+			if err != nil {
+				log.Printf("Error rescheduling rate fetching, keeping %q: %v", schedule, err)
+			} else {
+				c.Remove(fetchJob)
+				fetchJob, schedule = job, cfg.Schedule
+			}
+		}
+		log.Printf("Reloaded config from %s", configPath)
+	})
+
+	// Prune rate history older than the retention window once a day
+	_, err = c.AddFunc("@daily", func() {
+		removed, err := db.PruneRateHistory(time.Now().Add(-historyRetention))
+		if err != nil {
+			log.Printf("Error pruning rate history: %v", err)
+			return
+		}
+		log.Printf("Pruned %d rate history entries", removed)
+	})
+	if err != nil {
+		log.Fatal("Error setting up history retention job:", err)
+	}
+
 	// Start the cron scheduler
 	c.Start()
 
@@ -360,7 +562,7 @@ func main() {
 
 		// Handle different commands
 		switch {
-		case update.Message.Text == "/start":
+		case strings.HasPrefix(update.Message.Text, "/start"):
 			err := db.AddSubscriber(update.Message.Chat.ID)
 			if err != nil {
 				log.Printf("Error adding subscriber: %v", err)
@@ -370,8 +572,23 @@ func main() {
 				continue
 			}
 			activeChatIDs[update.Message.Chat.ID] = true
-			msg := tgbotapi.NewMessage(update.Message.Chat.ID,
-				"Welcome! You will now receive notifications when lending rates exceed thresholds.")
+
+			// Optionally limit notifications to the given tokens
+			tokens := parseTokens(strings.Fields(update.Message.Text)[1:])
+			if err := watchTokens(update.Message.Chat.ID, tokens); err != nil {
+				log.Printf("Error adding subscriptions: %v", err)
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID,
+					"Sorry, there was an error saving your token list. Please try again later.")
+				sendTelegramMessage(bot, msg)
+				continue
+			}
+
+			text := "Welcome! You will now receive notifications when lending rates exceed thresholds."
+			if len(tokens) > 0 {
+				text = fmt.Sprintf("Welcome! You will now receive notifications when %s lending rates exceed thresholds.",
+					strings.Join(tokens, ", "))
+			}
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
 			sendTelegramMessage(bot, msg)
 
 		case update.Message.Text == "/stop":
@@ -390,7 +607,9 @@ func main() {
 
 		case strings.HasPrefix(update.Message.Text, "/rate"):
 			// Use cached rates or fetch new ones
-			allRates, err := getRatesWithCache(sources...)
+			ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+			allRates, err := getRatesWithCache(ctx, currentSources()...)
+			cancel()
 			if err != nil {
 				msg := tgbotapi.NewMessage(update.Message.Chat.ID,
 					"Error fetching rates. Please try again later.")
@@ -405,6 +624,10 @@ func main() {
 				continue
 			}
 
+			// Show rates as APR or APY depending on the chat's preference
+			rateKind := getChatRateKind(update.Message.Chat.ID)
+			allRates = normalizeRates(allRates, rateKind)
+
 			// Parse the token from command (e.g., "/rate USDT" or just "/rate")
 			parts := strings.Fields(update.Message.Text)
 			var message strings.Builder
@@ -412,7 +635,7 @@ func main() {
 			if len(parts) > 1 {
 				// Query specific token
 				token := strings.ToUpper(parts[1])
-				message.WriteString(fmt.Sprintf("*Current Rates for %s*\n", token))
+				message.WriteString(fmt.Sprintf("*Current Rates for %s* (%s)\n", token, rateKind))
 
 				found := false
 				tokenRates := []Rate{}
@@ -438,15 +661,22 @@ func main() {
 					return tokenRates[i].Source < tokenRates[j].Source
 				})
 
-				threshold := lendingThresholds[token]
+				threshold := getChatThresholds(update.Message.Chat.ID)[token]
 				for _, rate := range tokenRates {
 					message.WriteString(formatRate(rate, threshold))
 					message.WriteString("\n")
+					// Show the deposit bands behind tiered rates
+					if len(rate.Tiers) > 1 {
+						message.WriteString(formatTiers(rate.Tiers))
+					}
+					message.WriteString(formatMarketStats(rate))
 				}
 
 			} else {
 				// Show all rates
-				message.WriteString("*Current Rates for All Tokens*\n")
+				message.WriteString(fmt.Sprintf("*Current Rates for All Tokens* (%s)\n", rateKind))
+
+				thresholds := getChatThresholds(update.Message.Chat.ID)
 
 				// Group rates by token
 				ratesByToken := make(map[string][]Rate)
@@ -470,7 +700,7 @@ func main() {
 						return rates[i].Source < rates[j].Source
 					})
 
-					threshold := lendingThresholds[token]
+					threshold := thresholds[token]
 					for _, rate := range rates {
 						message.WriteString(formatRate(rate, threshold))
 						message.WriteString("\n")
@@ -483,6 +713,432 @@ func main() {
 			msg.ParseMode = "markdown"
 			sendTelegramMessage(bot, msg)
 
+		case strings.HasPrefix(update.Message.Text, "/history"):
+			parts := strings.Fields(update.Message.Text)
+			if len(parts) < 2 {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID,
+					"Usage: /history <token> [period]\nExample: /history USDT 7d")
+				sendTelegramMessage(bot, msg)
+				continue
+			}
+
+			token := strings.ToUpper(parts[1])
+			periodStr := ""
+			if len(parts) > 2 {
+				periodStr = parts[2]
+			}
+			period, err := parsePeriod(periodStr)
+			if err != nil {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID,
+					"Invalid period. Use a number followed by h, d or w, e.g. 24h, 7d, 2w.")
+				sendTelegramMessage(bot, msg)
+				continue
+			}
+
+			history, err := loadChatHistory(update.Message.Chat.ID, token, period)
+			if err != nil {
+				log.Printf("Error loading rate history: %v", err)
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID,
+					"Error loading rate history. Please try again later.")
+				sendTelegramMessage(bot, msg)
+				continue
+			}
+
+			if len(history) == 0 {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID,
+					fmt.Sprintf("No rate history found for token: %s", token))
+				sendTelegramMessage(bot, msg)
+				continue
+			}
+
+			rateKind := getChatRateKind(update.Message.Chat.ID)
+			history = normalizeRates(history, rateKind)
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID,
+				formatHistory(token, period, rateKind, summarizeHistory(history)))
+			msg.ParseMode = "markdown"
+			sendTelegramMessage(bot, msg)
+
+		case strings.HasPrefix(update.Message.Text, "/chart"):
+			parts := strings.Fields(update.Message.Text)
+			if len(parts) < 2 {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID,
+					"Usage: /chart <token> [period] [borrow]\nExample: /chart USDT 7d borrow")
+				sendTelegramMessage(bot, msg)
+				continue
+			}
+
+			token := strings.ToUpper(parts[1])
+			periodStr := ""
+			includeBorrow := false
+			for _, arg := range parts[2:] {
+				if strings.EqualFold(arg, "borrow") {
+					includeBorrow = true
+				} else {
+					periodStr = arg
+				}
+			}
+			period, err := parsePeriod(periodStr)
+			if err != nil {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID,
+					"Invalid period. Use a number followed by h, d or w, e.g. 24h, 7d, 2w.")
+				sendTelegramMessage(bot, msg)
+				continue
+			}
+
+			history, err := loadChatHistory(update.Message.Chat.ID, token, period)
+			if err != nil {
+				log.Printf("Error loading rate history: %v", err)
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID,
+					"Error loading rate history. Please try again later.")
+				sendTelegramMessage(bot, msg)
+				continue
+			}
+
+			if len(history) == 0 {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID,
+					fmt.Sprintf("No rate history found for token: %s", token))
+				sendTelegramMessage(bot, msg)
+				continue
+			}
+
+			chart, err := renderRateChart(token, history, includeBorrow, getChatRateKind(update.Message.Chat.ID))
+			if err != nil {
+				log.Printf("Error rendering chart: %v", err)
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID,
+					"Error rendering chart. Please try again later.")
+				sendTelegramMessage(bot, msg)
+				continue
+			}
+
+			photo := tgbotapi.NewPhoto(update.Message.Chat.ID, tgbotapi.FileBytes{
+				Name:  fmt.Sprintf("%s_%s.png", token, formatPeriod(period)),
+				Bytes: chart,
+			})
+			photo.Caption = fmt.Sprintf("%s rates over the last %s", token, formatPeriod(period))
+			sendTelegramPhoto(bot, photo)
+
+		case strings.HasPrefix(update.Message.Text, "/threshold"):
+			chatID := update.Message.Chat.ID
+			parts := strings.Fields(update.Message.Text)
+
+			if len(parts) == 1 {
+				overrides, err := db.GetThresholds(chatID)
+				if err != nil {
+					log.Printf("Error loading thresholds: %v", err)
+					msg := tgbotapi.NewMessage(chatID,
+						"Sorry, there was an error loading your thresholds. Please try again later.")
+					sendTelegramMessage(bot, msg)
+					continue
+				}
+				borrowOverrides, err := db.GetBorrowThresholds(chatID)
+				if err != nil {
+					log.Printf("Error loading borrow thresholds: %v", err)
+					msg := tgbotapi.NewMessage(chatID,
+						"Sorry, there was an error loading your thresholds. Please try again later.")
+					sendTelegramMessage(bot, msg)
+					continue
+				}
+				msg := tgbotapi.NewMessage(chatID,
+					formatThresholds(getChatThresholds(chatID), overrides)+"\n\n"+
+						formatBorrowThresholds(getChatBorrowThresholds(chatID), borrowOverrides))
+				msg.ParseMode = "markdown"
+				sendTelegramMessage(bot, msg)
+				continue
+			}
+
+			if len(parts) >= 3 && strings.EqualFold(parts[2], "borrow") {
+				token := strings.ToUpper(parts[1])
+				usage := "Usage: /threshold <token> borrow <above|below> <percent>, /threshold <token> borrow reset\n" +
+					"Example: /threshold USDT borrow above 20"
+
+				var reply string
+				switch {
+				case len(parts) == 4 && strings.EqualFold(parts[3], "reset"):
+					err = db.RemoveBorrowThresholds(chatID, token)
+					reply = fmt.Sprintf("%s borrow thresholds reset to the defaults.", token)
+				case len(parts) == 5 && (strings.EqualFold(parts[3], "above") || strings.EqualFold(parts[3], "below")):
+					value, parseErr := parsePercent(parts[4])
+					if parseErr != nil {
+						sendTelegramMessage(bot, tgbotapi.NewMessage(chatID, usage))
+						continue
+					}
+					if strings.EqualFold(parts[3], "above") {
+						err = db.SetBorrowCeiling(chatID, token, value)
+						reply = fmt.Sprintf("You will be notified when %s borrow rates rise to %.1f%%.", token, value)
+					} else {
+						err = db.SetBorrowFloor(chatID, token, value)
+						reply = fmt.Sprintf("You will be notified when %s borrow rates drop to %.1f%%.", token, value)
+					}
+				default:
+					sendTelegramMessage(bot, tgbotapi.NewMessage(chatID, usage))
+					continue
+				}
+
+				if err != nil {
+					log.Printf("Error saving borrow threshold: %v", err)
+					msg := tgbotapi.NewMessage(chatID,
+						"Sorry, there was an error saving your threshold. Please try again later.")
+					sendTelegramMessage(bot, msg)
+					continue
+				}
+				sendTelegramMessage(bot, tgbotapi.NewMessage(chatID, reply))
+				continue
+			}
+
+			if len(parts) != 3 {
+				msg := tgbotapi.NewMessage(chatID,
+					"Usage: /threshold <token> <percent|reset>\nExample: /threshold USDT 25")
+				sendTelegramMessage(bot, msg)
+				continue
+			}
+
+			token := strings.ToUpper(parts[1])
+			var reply string
+			if strings.EqualFold(parts[2], "reset") {
+				err = db.RemoveThreshold(chatID, token)
+				if defaultThreshold, exists := currentConfig().LendingThresholds[token]; exists {
+					reply = fmt.Sprintf("%s threshold reset to the default of %.1f%%.", token, defaultThreshold)
+				} else {
+					reply = fmt.Sprintf("%s threshold removed.", token)
+				}
+			} else {
+				threshold, parseErr := parsePercent(parts[2])
+				if parseErr != nil {
+					msg := tgbotapi.NewMessage(chatID,
+						"Invalid percentage. Example: /threshold USDT 25")
+					sendTelegramMessage(bot, msg)
+					continue
+				}
+				err = db.SetThreshold(chatID, token, threshold)
+				reply = fmt.Sprintf("You will be notified when %s lending rates reach %.1f%%.", token, threshold)
+			}
+
+			if err != nil {
+				log.Printf("Error saving threshold: %v", err)
+				msg := tgbotapi.NewMessage(chatID,
+					"Sorry, there was an error saving your threshold. Please try again later.")
+				sendTelegramMessage(bot, msg)
+				continue
+			}
+			sendTelegramMessage(bot, tgbotapi.NewMessage(chatID, reply))
+
+		case strings.HasPrefix(update.Message.Text, "/watch"):
+			chatID := update.Message.Chat.ID
+			tokens := parseTokens(strings.Fields(update.Message.Text)[1:])
+			var err error
+			if len(tokens) == 1 && tokens[0] == "ALL" {
+				err = db.ClearSubscriptions(chatID)
+			} else {
+				err = watchTokens(chatID, tokens)
+			}
+			if err != nil {
+				log.Printf("Error adding subscriptions: %v", err)
+				msg := tgbotapi.NewMessage(chatID,
+					"Sorry, there was an error saving your token list. Please try again later.")
+				sendTelegramMessage(bot, msg)
+				continue
+			}
+
+			watched, err := db.GetSubscriptions(chatID)
+			if err != nil {
+				log.Printf("Error loading subscriptions: %v", err)
+				msg := tgbotapi.NewMessage(chatID,
+					"Sorry, there was an error loading your token list. Please try again later.")
+				sendTelegramMessage(bot, msg)
+				continue
+			}
+			sendTelegramMessage(bot, tgbotapi.NewMessage(chatID, formatWatchlist(watched)))
+
+		case strings.HasPrefix(update.Message.Text, "/unwatch"):
+			chatID := update.Message.Chat.ID
+			tokens := parseTokens(strings.Fields(update.Message.Text)[1:])
+			if len(tokens) == 0 {
+				msg := tgbotapi.NewMessage(chatID,
+					"Usage: /unwatch <tokens...>\nExample: /unwatch TIA")
+				sendTelegramMessage(bot, msg)
+				continue
+			}
+
+			watched, err := db.GetSubscriptions(chatID)
+			if err != nil {
+				log.Printf("Error loading subscriptions: %v", err)
+				msg := tgbotapi.NewMessage(chatID,
+					"Sorry, there was an error loading your token list. Please try again later.")
+				sendTelegramMessage(bot, msg)
+				continue
+			}
+			if _, refusal := planUnwatch(watched, tokens); refusal != "" {
+				sendTelegramMessage(bot, tgbotapi.NewMessage(chatID, refusal))
+				continue
+			}
+
+			failed := false
+			for _, token := range tokens {
+				if err := db.RemoveSubscription(chatID, token); err != nil {
+					log.Printf("Error removing subscription: %v", err)
+					failed = true
+				}
+			}
+			if failed {
+				msg := tgbotapi.NewMessage(chatID,
+					"Sorry, there was an error saving your token list. Please try again later.")
+				sendTelegramMessage(bot, msg)
+				continue
+			}
+
+			watched, err = db.GetSubscriptions(chatID)
+			if err != nil {
+				log.Printf("Error loading subscriptions: %v", err)
+				msg := tgbotapi.NewMessage(chatID,
+					"Sorry, there was an error loading your token list. Please try again later.")
+				sendTelegramMessage(bot, msg)
+				continue
+			}
+			sendTelegramMessage(bot, tgbotapi.NewMessage(chatID, formatWatchlist(watched)))
+
+		case strings.HasPrefix(update.Message.Text, "/alert"):
+			chatID := update.Message.Chat.ID
+			parts := strings.Fields(update.Message.Text)
+			action := "list"
+			if len(parts) > 1 {
+				action = strings.ToLower(parts[1])
+			}
+
+			switch action {
+			case "add":
+				rule, err := parseAlertRule(parts[2:])
+				if err != nil {
+					msg := tgbotapi.NewMessage(chatID,
+						fmt.Sprintf("Invalid rule: %v\nExample: /alert add USDT lend > 25 source=Neptune", err))
+					sendTelegramMessage(bot, msg)
+					continue
+				}
+				rule.ChatID = chatID
+				id, err := db.AddAlertRule(rule)
+				if err != nil {
+					log.Printf("Error saving alert rule: %v", err)
+					msg := tgbotapi.NewMessage(chatID,
+						"Sorry, there was an error saving your alert. Please try again later.")
+					sendTelegramMessage(bot, msg)
+					continue
+				}
+				sendTelegramMessage(bot, tgbotapi.NewMessage(chatID,
+					fmt.Sprintf("Alert #%d added: %s", id, rule)))
+
+			case "rm", "remove", "del":
+				if len(parts) != 3 {
+					sendTelegramMessage(bot, tgbotapi.NewMessage(chatID, "Usage: /alert rm <id>"))
+					continue
+				}
+				id, err := strconv.ParseInt(strings.TrimPrefix(parts[2], "#"), 10, 64)
+				if err != nil {
+					sendTelegramMessage(bot, tgbotapi.NewMessage(chatID, "Usage: /alert rm <id>"))
+					continue
+				}
+				removed, err := db.RemoveAlertRule(chatID, id)
+				if err != nil {
+					log.Printf("Error removing alert rule: %v", err)
+					msg := tgbotapi.NewMessage(chatID,
+						"Sorry, there was an error removing your alert. Please try again later.")
+					sendTelegramMessage(bot, msg)
+					continue
+				}
+				reply := fmt.Sprintf("Alert #%d removed.", id)
+				if !removed {
+					reply = fmt.Sprintf("Alert #%d not found.", id)
+				}
+				sendTelegramMessage(bot, tgbotapi.NewMessage(chatID, reply))
+
+			case "list":
+				rules, err := db.GetAlertRules(chatID)
+				if err != nil {
+					log.Printf("Error loading alert rules: %v", err)
+					msg := tgbotapi.NewMessage(chatID,
+						"Sorry, there was an error loading your alerts. Please try again later.")
+					sendTelegramMessage(bot, msg)
+					continue
+				}
+				msg := tgbotapi.NewMessage(chatID, formatAlertRules(rules))
+				msg.ParseMode = "markdown"
+				sendTelegramMessage(bot, msg)
+
+			default:
+				sendTelegramMessage(bot, tgbotapi.NewMessage(chatID,
+					"Usage: /alert add <rule>, /alert list, /alert rm <id>"))
+			}
+
+		case strings.HasPrefix(update.Message.Text, "/sources"):
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID,
+				formatSourceHealth(sourceHealth.Snapshot(), time.Now()))
+			msg.ParseMode = "markdown"
+			sendTelegramMessage(bot, msg)
+
+		case strings.HasPrefix(update.Message.Text, "/yield"):
+			chatID := update.Message.Chat.ID
+			args := strings.Fields(update.Message.Text)[1:]
+			if len(args) != 2 {
+				sendTelegramMessage(bot, tgbotapi.NewMessage(chatID,
+					"Usage: /yield <token> <amount>\nExample: /yield USDT 250k"))
+				continue
+			}
+			token := strings.ToUpper(args[0])
+			amount, err := parseAmount(args[1])
+			if err != nil {
+				sendTelegramMessage(bot, tgbotapi.NewMessage(chatID, fmt.Sprintf("Invalid amount: %v", err)))
+				continue
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+			allRates, err := getRatesWithCache(ctx, currentSources()...)
+			cancel()
+			if err != nil {
+				sendTelegramMessage(bot, tgbotapi.NewMessage(chatID,
+					"Error fetching rates. Please try again later."))
+				continue
+			}
+
+			var tokenRates []Rate
+			for _, rate := range allRates {
+				if rate.Category == "CEX" && !shouldShowCEXRates(chatID) {
+					continue
+				}
+				tokenRates = append(tokenRates, rate)
+			}
+			estimates := estimateYields(tokenRates, token, amount)
+			if len(estimates) == 0 {
+				sendTelegramMessage(bot, tgbotapi.NewMessage(chatID,
+					fmt.Sprintf("No rates found for token: %s", token)))
+				continue
+			}
+
+			msg := tgbotapi.NewMessage(chatID, formatYieldEstimates(token, amount, estimates))
+			msg.ParseMode = "markdown"
+			sendTelegramMessage(bot, msg)
+
+		case strings.HasPrefix(update.Message.Text, "/display"):
+			chatID := update.Message.Chat.ID
+			args := strings.Fields(update.Message.Text)[1:]
+			if len(args) == 0 {
+				sendTelegramMessage(bot, tgbotapi.NewMessage(chatID,
+					fmt.Sprintf("Rates are shown as %s. Usage: /display <apr|apy>", getChatRateKind(chatID))))
+				continue
+			}
+
+			kind, err := parseRateKind(args[0])
+			if err != nil {
+				sendTelegramMessage(bot, tgbotapi.NewMessage(chatID,
+					fmt.Sprintf("Invalid display mode: %v", err)))
+				continue
+			}
+			if err := db.SetRateKind(chatID, kind); err != nil {
+				log.Printf("Error saving rate display preference: %v", err)
+				sendTelegramMessage(bot, tgbotapi.NewMessage(chatID,
+					"Sorry, there was an error saving your preference. Please try again later."))
+				continue
+			}
+			sendTelegramMessage(bot, tgbotapi.NewMessage(chatID,
+				fmt.Sprintf("Rates are now shown as %s.", kind)))
+
 		case update.Message.Text == "/help":
 			msg := tgbotapi.NewMessage(update.Message.Chat.ID, getHelpMessage())
 			msg.ParseMode = "markdown"
@@ -522,6 +1178,13 @@ func sendTelegramMessage(bot *tgbotapi.BotAPI, msg tgbotapi.MessageConfig) {
 	}
 }
 
+func sendTelegramPhoto(bot *tgbotapi.BotAPI, photo tgbotapi.PhotoConfig) {
+	_, err := bot.Send(photo)
+	if err != nil {
+		log.Printf("Error sending Telegram photo: %v, caption: %s", err, photo.Caption)
+	}
+}
+
 func joinStrings(strings []string, separator string) string {
 	result := ""
 	for i, s := range strings {
@@ -547,11 +1210,12 @@ func getEnv(key, defaultValue string) string {
 func formatRate(rate Rate, threshold float64) string {
 	var lendingRateStr, borrowRateStr, emoji string
 
-	// Format lending rate with right alignment
-	if rate.LendingRate >= threshold*2 {
+	// Format lending rate with right alignment, marking rates above the
+	// token's threshold if it has one
+	if threshold > 0 && rate.LendingRate >= threshold*2 {
 		lendingRateStr = fmt.Sprintf("%4.0f%%", rate.LendingRate)
 		emoji = " 🔥"
-	} else if rate.LendingRate >= threshold {
+	} else if threshold > 0 && rate.LendingRate >= threshold {
 		lendingRateStr = fmt.Sprintf("%4.0f%%", rate.LendingRate)
 		emoji = " 🚀"
 	} else {
@@ -561,20 +1225,21 @@ func formatRate(rate Rate, threshold float64) string {
 	// Format borrow rate with right alignment
 	borrowRateStr = fmt.Sprintf("%5.0f%%", rate.BorrowRate)
 
+	// Mark last known good rates served while their source is failing
+	if rate.Stale {
+		emoji += " ⏳ " + formatAge(time.Since(rate.FetchedAt))
+	}
+
 	// Using monospace formatting for Telegram with minimal spacing
 	return fmt.Sprintf("`%-8s%7s│%6s`%s",
 		rate.Source, lendingRateStr, borrowRateStr, emoji)
 }
 
-// convertAPRtoAPY converts APR to APY
-// compounds is the number of times interest is compounded per year
-func convertAPRtoAPY(apr float64, compounds int) float64 {
-	// Convert percentage to decimal
-	aprDecimal := apr / 100
-
-	// Calculate APY
-	apy := math.Pow(1+aprDecimal/float64(compounds), float64(compounds)) - 1
-
-	// Convert back to percentage
-	return apy * 100
+// formatMarketStats shows a lending market's utilization and total supply
+// below its row in /rate, if the source reports them
+func formatMarketStats(rate Rate) string {
+	if rate.Utilization == 0 && rate.TotalSupply == 0 {
+		return ""
+	}
+	return fmt.Sprintf("`  util %3.0f%%  supply %s`\n", rate.Utilization, formatAmount(math.Round(rate.TotalSupply)))
 }
@@ -1,8 +1,12 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestGetEnv(t *testing.T) {
@@ -155,3 +159,253 @@ func TestCombineUpdates(t *testing.T) {
 		})
 	}
 }
+
+func TestHasSignificantChange(t *testing.T) {
+	tests := []struct {
+		name       string
+		oldRate    Rate
+		newRate    Rate
+		wantLend   bool
+		wantBorrow bool
+	}{
+		{
+			name:       "first observation",
+			newRate:    Rate{LendingRate: 10, BorrowRate: 12},
+			wantLend:   true,
+			wantBorrow: true,
+		},
+		{
+			name:       "small moves",
+			oldRate:    Rate{LendingRate: 10, BorrowRate: 20},
+			newRate:    Rate{LendingRate: 10.2, BorrowRate: 20.5},
+			wantLend:   false,
+			wantBorrow: false,
+		},
+		{
+			name:       "borrow spike only",
+			oldRate:    Rate{LendingRate: 10, BorrowRate: 20},
+			newRate:    Rate{LendingRate: 10, BorrowRate: 30},
+			wantLend:   false,
+			wantBorrow: true,
+		},
+		{
+			name:       "lending drop only",
+			oldRate:    Rate{LendingRate: 10, BorrowRate: 20},
+			newRate:    Rate{LendingRate: 5, BorrowRate: 20},
+			wantLend:   true,
+			wantBorrow: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasSignificantChange(tt.oldRate, tt.newRate); got != tt.wantLend {
+				t.Errorf("hasSignificantChange() = %v, want %v", got, tt.wantLend)
+			}
+			if got := hasSignificantBorrowChange(tt.oldRate, tt.newRate); got != tt.wantBorrow {
+				t.Errorf("hasSignificantBorrowChange() = %v, want %v", got, tt.wantBorrow)
+			}
+		})
+	}
+}
+
+// stubRateSource is a RateSource returning canned rates after an optional delay
+type stubRateSource struct {
+	name  string
+	rates []Rate
+	err   error
+	delay time.Duration
+}
+
+func (s *stubRateSource) Name() string {
+	return s.name
+}
+
+func (s *stubRateSource) FetchRates(ctx context.Context) ([]Rate, error) {
+	select {
+	case <-time.After(s.delay):
+		return s.rates, s.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestFetchRates(t *testing.T) {
+	originalTimeout := sourceTimeout
+	sourceTimeout = 100 * time.Millisecond
+	defer func() { sourceTimeout = originalTimeout }()
+
+	fast := &stubRateSource{name: "Fast", rates: []Rate{{Source: "Fast", Token: "USDT", LendingRate: 10}}}
+	alsoFast := &stubRateSource{name: "AlsoFast", rates: []Rate{{Source: "AlsoFast", Token: "USDC", LendingRate: 5}}}
+	slow := &stubRateSource{name: "Slow", rates: []Rate{{Source: "Slow", Token: "USDT"}}, delay: time.Minute}
+	broken := &stubRateSource{name: "Broken", err: errors.New("boom")}
+
+	start := time.Now()
+	rates, sourceErrors, err := fetchRates(context.Background(), fast, slow, broken, alsoFast)
+	if err != nil {
+		t.Fatalf("fetchRates() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("fetchRates() took %v, slow source should have timed out", elapsed)
+	}
+
+	if len(rates) != 2 || rates[0].Source != "Fast" || rates[1].Source != "AlsoFast" {
+		t.Errorf("fetchRates() rates = %+v, want Fast and AlsoFast in source order", rates)
+	}
+
+	failed := map[string]error{}
+	for _, sourceError := range sourceErrors {
+		failed[sourceError.Source] = sourceError.Err
+	}
+	if len(failed) != 2 {
+		t.Fatalf("fetchRates() source errors = %v, want Slow and Broken", sourceErrors)
+	}
+	if !errors.Is(failed["Slow"], context.DeadlineExceeded) {
+		t.Errorf("Slow source error = %v, want deadline exceeded", failed["Slow"])
+	}
+
+	// Cancelling the parent context stops every source
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, sourceErrors, err = fetchRates(ctx, slow)
+	if err == nil || len(sourceErrors) != 1 || !errors.Is(sourceErrors[0].Err, context.Canceled) {
+		t.Errorf("fetchRates() with cancelled context = %v, %v, want cancellation", sourceErrors, err)
+	}
+}
+
+func TestFetchRates_ServesLastKnownGood(t *testing.T) {
+	flaky := &stubRateSource{name: "Flapping", rates: []Rate{{Source: "Flapping", Token: "USDT", LendingRate: 12}}}
+	healthy := &stubRateSource{name: "Steady", rates: []Rate{{Source: "Steady", Token: "USDC", LendingRate: 5}}}
+
+	if _, _, err := fetchRates(context.Background(), flaky, healthy); err != nil {
+		t.Fatalf("fetchRates() error = %v", err)
+	}
+
+	flaky.rates, flaky.err = nil, errors.New("boom")
+	rates, sourceErrors, err := fetchRates(context.Background(), flaky, healthy)
+	if err != nil || len(sourceErrors) != 1 {
+		t.Fatalf("fetchRates() = %v, %v, want one source error", sourceErrors, err)
+	}
+	if len(rates) != 2 || !rates[0].Stale || rates[0].LendingRate != 12 || rates[1].Stale {
+		t.Errorf("fetchRates() rates = %+v, want stale Flapping rate then fresh Steady rate", rates)
+	}
+	if fresh := freshRates(rates); len(fresh) != 1 || fresh[0].Source != "Steady" {
+		t.Errorf("freshRates() = %+v, want only Steady", fresh)
+	}
+
+	// Last known good rates expire after lastGoodMaxAge
+	if stale := lastGoodRates("Flapping", time.Now().Add(lastGoodMaxAge+time.Minute)); len(stale) != 0 {
+		t.Errorf("lastGoodRates() after max age = %+v, want none", stale)
+	}
+}
+
+func TestFetchRates_PartialFailure(t *testing.T) {
+	partial := &stubRateSource{name: "Partial", rates: []Rate{
+		{Source: "Partial", Token: "USDT", LendingRate: 12},
+		{Source: "Partial", Token: "USDC", LendingRate: 8},
+	}}
+	if _, _, err := fetchRates(context.Background(), partial); err != nil {
+		t.Fatalf("fetchRates() error = %v", err)
+	}
+
+	// USDC fails: the fresh USDT rate is served with USDC's last good rate
+	partial.rates = nil
+	partial.err = &PartialError{
+		Rates: []Rate{{Source: "Partial", Token: "USDT", LendingRate: 13}},
+		Err:   errors.New("USDC: boom"),
+	}
+	rates, sourceErrors, err := fetchRates(context.Background(), partial)
+	if err != nil || len(sourceErrors) != 1 {
+		t.Fatalf("fetchRates() = %v, %v, want one source error", sourceErrors, err)
+	}
+	byToken := make(map[string]Rate)
+	for _, rate := range rates {
+		byToken[rate.Token] = rate
+	}
+	if usdt := byToken["USDT"]; len(rates) != 2 || usdt.Stale || usdt.LendingRate != 13 {
+		t.Errorf("fetchRates() rates = %+v, want fresh USDT at 13%%", rates)
+	}
+	if usdc := byToken["USDC"]; !usdc.Stale || usdc.LendingRate != 8 {
+		t.Errorf("fetchRates() rates = %+v, want stale USDC at 8%%", rates)
+	}
+	health, _ := healthOf(sourceHealth, "Partial")
+	if !health.Healthy() || !health.Degraded || health.RateCount != 1 || health.LastError == "" {
+		t.Errorf("source health = %+v, want a degraded success with the error kept", health)
+	}
+	if status := health.Status(time.Now()); status != SourceDegraded {
+		t.Errorf("Status() after a partial fetch = %s, want degraded", status)
+	}
+
+	// USDC stays served from the last good fetch while it keeps failing
+	partial.err = &PartialError{
+		Rates: []Rate{{Source: "Partial", Token: "USDT", LendingRate: 14}},
+		Err:   errors.New("USDC: boom"),
+	}
+	rates, _, _ = fetchRates(context.Background(), partial)
+	if len(rates) != 2 || len(freshRates(rates)) != 1 {
+		t.Errorf("fetchRates() rates = %+v, want fresh USDT and stale USDC", rates)
+	}
+}
+
+func TestFormatRate_Stale(t *testing.T) {
+	rate := Rate{Source: "OKX", Token: "USDT", LendingRate: 10, FetchedAt: time.Now().Add(-14 * time.Minute)}
+	if got := formatRate(rate, 30); strings.Contains(got, "⏳") {
+		t.Errorf("formatRate() for fresh rate = %q, want no staleness marker", got)
+	}
+
+	rate.Stale = true
+	if got := formatRate(rate, 30); !strings.HasSuffix(got, "⏳ 14m") {
+		t.Errorf("formatRate() for stale rate = %q, want ⏳ 14m suffix", got)
+	}
+}
+
+func TestFormatRate_Markers(t *testing.T) {
+	tests := []struct {
+		lending   float64
+		threshold float64
+		want      string
+	}{
+		{65, 30, "🔥"},
+		{35, 30, "🚀"},
+		{10, 30, ""},
+		{10, 0, ""}, // Tokens without a threshold are never marked
+	}
+	for _, tt := range tests {
+		got := formatRate(Rate{Source: "Injera", Token: "INJ", LendingRate: tt.lending}, tt.threshold)
+		hasMarker := strings.ContainsAny(got, "🔥🚀")
+		if (tt.want == "" && hasMarker) || (tt.want != "" && !strings.Contains(got, tt.want)) {
+			t.Errorf("formatRate(%v%%, threshold %v) = %q, want marker %q", tt.lending, tt.threshold, got, tt.want)
+		}
+	}
+}
+
+func TestFormatMarketStats(t *testing.T) {
+	if got := formatMarketStats(Rate{Source: "OKX", LendingRate: 10}); got != "" {
+		t.Errorf("formatMarketStats() without stats = %q, want empty", got)
+	}
+
+	got := formatMarketStats(Rate{Source: "Injera", Utilization: 72.4, TotalSupply: 1499999.6})
+	if want := "`  util  72%  supply 1.5M`\n"; got != want {
+		t.Errorf("formatMarketStats() = %q, want %q", got, want)
+	}
+}
+
+func TestFetchRates_ConvertsAPR(t *testing.T) {
+	source := &stubRateSource{name: "Lender", rates: []Rate{
+		{Source: "Lender", Token: "USDT", LendingRate: 10, RawLendingRate: 10, Kind: RateKindAPR, CompoundsPerYear: 365},
+		{Source: "Lender", Token: "USDC", LendingRate: 10, RawLendingRate: 10, Kind: RateKindAPY, CompoundsPerYear: 365},
+	}}
+
+	rates, _, err := fetchRates(context.Background(), source)
+	if err != nil {
+		t.Fatalf("fetchRates() error = %v", err)
+	}
+
+	want := convertAPRtoAPY(10, 365)
+	if rates[0].Kind != RateKindAPY || rates[0].LendingRate != want || rates[0].RawLendingRate != 10 {
+		t.Errorf("fetchRates() APR rate = %+v, want APY %.4f with raw 10", rates[0], want)
+	}
+	if rates[1].LendingRate != 10 {
+		t.Errorf("fetchRates() APY rate = %+v, want unchanged 10", rates[1])
+	}
+}
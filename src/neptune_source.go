@@ -1,17 +1,22 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"strconv"
+	"time"
 )
 
 type NeptuneSource struct {
-	APIURL   string
-	Tokens   map[string]string
-	Category string
+	APIURL     string
+	Registry   *DenomRegistry
+	Category   string
+	Convention RateConvention
+
+	unknownDenoms
 }
 
 type NeptuneResponse struct {
@@ -21,26 +26,36 @@ type NeptuneResponse struct {
 
 func NewNeptuneSource() *NeptuneSource {
 	return &NeptuneSource{
-		APIURL: "https://neptune-api-production-6ojz3.ondigitalocean.app/v1/aprs?refresh=false",
-		Tokens: map[string]string{
-			"ibc/2CBC2EA121AE42563B08028466F37B600F2D7D4282342DE938283CC3FB2BC00E": "USDC",
-			"ibc/F51BB221BAA275F2EBF654F70B005627D7E713AFFD6D86AFD1E43CAA886149F4": "TIA",
-			"peggy0xdAC17F958D2ee523a2206206994597C13D831ec7":                      "USDT",
-		},
-		Category: "DEX",
+		APIURL:     "https://neptune-api-production-6ojz3.ondigitalocean.app/v1/aprs?refresh=false",
+		Registry:   defaultDenomRegistry(),
+		Category:   "DEX",
+		Convention: dailyAPR, // Assuming daily compounding
 	}
 }
 
-func (s *NeptuneSource) FetchRates() ([]Rate, error) {
-	resp, err := http.Get(s.APIURL)
+func (s *NeptuneSource) Name() string {
+	return "Neptune"
+}
+
+func (s *NeptuneSource) FetchRates(ctx context.Context) ([]Rate, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", s.APIURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating Neptune request: %v", err)
+	}
+
+	resp, err := httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("error fetching Neptune data: %v", err)
+		return nil, fmt.Errorf("error fetching Neptune data: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if err := checkResponseStatus(resp); err != nil {
+		return nil, fmt.Errorf("error fetching Neptune data: %w", err)
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("error reading Neptune response body: %v", err)
+		return nil, fmt.Errorf("error reading Neptune response body: %w", err)
 	}
 
 	var neptuneResp NeptuneResponse
@@ -51,6 +66,9 @@ func (s *NeptuneSource) FetchRates() ([]Rate, error) {
 
 	// Create a map to store rates by token
 	ratesByToken := make(map[string]*Rate)
+	fetchedAt := time.Now()
+	unknown := make(map[string]bool)
+	listed := 0
 
 	processRates := func(ratesData [][]interface{}, rateType string) {
 		for _, rate := range ratesData {
@@ -69,9 +87,11 @@ func (s *NeptuneSource) FetchRates() ([]Rate, error) {
 			if !ok {
 				continue
 			}
-			tokenName, ok := s.Tokens[denom]
+			listed++
+			tokenName, ok := s.symbol(denom)
 			if !ok {
-				continue // Skip tokens not in the mapping
+				unknown[denom] = true
+				continue
 			}
 			rateStr, ok := rate[1].(string)
 			if !ok {
@@ -86,19 +106,24 @@ func (s *NeptuneSource) FetchRates() ([]Rate, error) {
 			// Get or create rate struct for this token
 			rateStruct, exists := ratesByToken[tokenName]
 			if !exists {
-				rateStruct = &Rate{
-					Source:   "Neptune",
-					Token:    tokenName,
-					Category: s.Category,
-				}
+				rate := s.Convention.apply(Rate{
+					Source:    "Neptune",
+					Token:     tokenName,
+					Category:  s.Category,
+					FetchedAt: fetchedAt,
+					SourceURL: s.APIURL,
+				})
+				rateStruct = &rate
 				ratesByToken[tokenName] = rateStruct
 			}
 
 			// Update the appropriate rate
 			if rateType == "Borrow" {
 				rateStruct.BorrowRate = ratePercent
+				rateStruct.RawBorrowRate = ratePercent
 			} else if rateType == "Lend" {
 				rateStruct.LendingRate = ratePercent
+				rateStruct.RawLendingRate = ratePercent
 			}
 		}
 	}
@@ -106,6 +131,7 @@ func (s *NeptuneSource) FetchRates() ([]Rate, error) {
 	// Process both types of rates
 	processRates(neptuneResp.LendAPRs, "Lend")
 	processRates(neptuneResp.BorrowAPRs, "Borrow")
+	s.unknownDenoms.update("Neptune", unknown)
 
 	// Convert map to slice
 	var rates []Rate
@@ -113,5 +139,15 @@ func (s *NeptuneSource) FetchRates() ([]Rate, error) {
 		rates = append(rates, *rate)
 	}
 
+	if listed > 0 && len(rates) == 0 {
+		return nil, fmt.Errorf("none of the %d Neptune rates could be reported, %d denoms are unknown",
+			listed, len(unknown))
+	}
 	return rates, nil
 }
+
+// symbol names a denom from the registry
+func (s *NeptuneSource) symbol(denom string) (string, bool) {
+	asset, ok := s.Registry.Lookup(denom)
+	return asset.Symbol, ok
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -63,7 +64,7 @@ func TestNeptuneSource_FetchRates(t *testing.T) {
 			source := NewNeptuneSource()
 			source.APIURL = server.URL
 
-			updates, err := source.FetchRates()
+			updates, err := source.FetchRates(context.Background())
 
 			if (err != nil) != tt.expectError {
 				t.Errorf("FetchRates() error = %v, expectError %v", err, tt.expectError)
@@ -84,6 +85,12 @@ func TestNeptuneSource_FetchRates(t *testing.T) {
 								t.Errorf("FetchRates() got rates %.2f/%.2f for %s, want %.2f/%.2f",
 									update.LendingRate, update.BorrowRate, currency, expectedRates[0], expectedRates[1])
 							}
+							if update.Kind != RateKindAPR || update.CompoundsPerYear != 365 ||
+								update.RawLendingRate != expectedRates[0] || update.FetchedAt.IsZero() || update.SourceURL != server.URL {
+								t.Errorf("FetchRates() got provenance %s/%d raw %.2f fetched %v url %q for %s, want APR/365 raw %.2f from %s",
+									update.Kind, update.CompoundsPerYear, update.RawLendingRate, update.FetchedAt, update.SourceURL,
+									currency, expectedRates[0], server.URL)
+							}
 							break
 						}
 					}
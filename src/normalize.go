@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// RateConvention declares how a source quotes its rates: as APR or APY, and
+// how often interest compounds
+type RateConvention struct {
+	Kind             RateKind
+	CompoundsPerYear int
+}
+
+// apply stamps the convention on a rate reported by the source
+func (c RateConvention) apply(rate Rate) Rate {
+	rate.Kind = c.Kind
+	rate.CompoundsPerYear = c.CompoundsPerYear
+	return rate
+}
+
+// Conventions of the built-in sources
+var (
+	dailyAPR  = RateConvention{Kind: RateKindAPR, CompoundsPerYear: 365}
+	dailyAPY  = RateConvention{Kind: RateKindAPY, CompoundsPerYear: 365}
+	hourlyAPY = RateConvention{Kind: RateKindAPY, CompoundsPerYear: 8760}
+)
+
+// defaultRateKind is the kind rates are stored, compared and shown in unless
+// a chat chooses otherwise
+const defaultRateKind = RateKindAPY
+
+// normalizeRate converts a rate's lending and borrow values to the given
+// kind using the rate's own compounding frequency. Rates without a known kind
+// or compounding are returned unchanged.
+func normalizeRate(rate Rate, kind RateKind) Rate {
+	if kind == "" || rate.Kind == "" || rate.Kind == kind || rate.CompoundsPerYear <= 0 {
+		return rate
+	}
+
+	convert := convertAPRtoAPY
+	if kind == RateKindAPR {
+		convert = convertAPYtoAPR
+	}
+	rate.LendingRate = convert(rate.LendingRate, rate.CompoundsPerYear)
+	rate.BorrowRate = convert(rate.BorrowRate, rate.CompoundsPerYear)
+	if rate.Tiers != nil {
+		tiers := make([]RateTier, len(rate.Tiers))
+		for i, tier := range rate.Tiers {
+			tier.LendingRate = convert(tier.LendingRate, rate.CompoundsPerYear)
+			tiers[i] = tier
+		}
+		rate.Tiers = tiers
+	}
+	rate.Kind = kind
+	return rate
+}
+
+// normalizeRates returns a copy of the rates converted to the given kind
+func normalizeRates(rates []Rate, kind RateKind) []Rate {
+	normalized := make([]Rate, len(rates))
+	for i, rate := range rates {
+		normalized[i] = normalizeRate(rate, kind)
+	}
+	return normalized
+}
+
+// parseRateKind parses "apr" or "apy" in any case
+func parseRateKind(s string) (RateKind, error) {
+	switch RateKind(strings.ToUpper(s)) {
+	case RateKindAPR:
+		return RateKindAPR, nil
+	case RateKindAPY:
+		return RateKindAPY, nil
+	default:
+		return "", fmt.Errorf("unknown rate kind %q, expected apr or apy", s)
+	}
+}
+
+// convertAPRtoAPY converts APR to APY
+// compounds is the number of times interest is compounded per year
+func convertAPRtoAPY(apr float64, compounds int) float64 {
+	// Convert percentage to decimal
+	aprDecimal := apr / 100
+
+	// Calculate APY
+	apy := math.Pow(1+aprDecimal/float64(compounds), float64(compounds)) - 1
+
+	// Convert back to percentage
+	return apy * 100
+}
+
+// convertAPYtoAPR converts APY to APR, the inverse of convertAPRtoAPY
+func convertAPYtoAPR(apy float64, compounds int) float64 {
+	apyDecimal := apy / 100
+	apr := float64(compounds) * (math.Pow(1+apyDecimal, 1/float64(compounds)) - 1)
+	return apr * 100
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNormalizeRate(t *testing.T) {
+	apr := dailyAPR.apply(Rate{Source: "Neptune", Token: "USDT", LendingRate: 10, BorrowRate: 20})
+	apy := hourlyAPY.apply(Rate{Source: "OKX", Token: "USDT", LendingRate: 10, BorrowRate: 20})
+
+	tests := []struct {
+		name       string
+		rate       Rate
+		kind       RateKind
+		wantKind   RateKind
+		wantLend   float64
+		wantBorrow float64
+	}{
+		{"APR to APY", apr, RateKindAPY, RateKindAPY, convertAPRtoAPY(10, 365), convertAPRtoAPY(20, 365)},
+		{"APY to APR", apy, RateKindAPR, RateKindAPR, convertAPYtoAPR(10, 8760), convertAPYtoAPR(20, 8760)},
+		{"same kind", apy, RateKindAPY, RateKindAPY, 10, 20},
+		{"unknown kind", Rate{LendingRate: 10, BorrowRate: 20}, RateKindAPR, "", 10, 20},
+		{"no target kind", apr, "", RateKindAPR, 10, 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := normalizeRate(tt.rate, tt.kind)
+			if got.Kind != tt.wantKind || got.LendingRate != tt.wantLend || got.BorrowRate != tt.wantBorrow {
+				t.Errorf("normalizeRate() = %s %.4f/%.4f, want %s %.4f/%.4f",
+					got.Kind, got.LendingRate, got.BorrowRate, tt.wantKind, tt.wantLend, tt.wantBorrow)
+			}
+		})
+	}
+}
+
+func TestConvertAPYtoAPR(t *testing.T) {
+	for _, compounds := range []int{1, 12, 365, 8760} {
+		apy := convertAPRtoAPY(25, compounds)
+		if got := convertAPYtoAPR(apy, compounds); math.Abs(got-25) > 1e-9 {
+			t.Errorf("convertAPYtoAPR(convertAPRtoAPY(25, %d)) = %v, want 25", compounds, got)
+		}
+	}
+}
+
+func TestParseRateKind(t *testing.T) {
+	for input, want := range map[string]RateKind{"apr": RateKindAPR, "APY": RateKindAPY} {
+		if got, err := parseRateKind(input); err != nil || got != want {
+			t.Errorf("parseRateKind(%q) = %v, %v, want %v", input, got, err, want)
+		}
+	}
+	if _, err := parseRateKind("apz"); err == nil {
+		t.Error("parseRateKind(\"apz\") should fail")
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// chatSettings holds the per-chat preferences that shape notifications
+type chatSettings struct {
+	thresholds map[string]float64
+	borrow     map[string]BorrowThreshold
+	showCEX    bool
+	rateKind   RateKind        // APR or APY for displayed rates
+	watched    map[string]bool // nil means every token
+	rules      []AlertRule     // custom rules followed by threshold rules
+}
+
+// loadChatSettings gathers a chat's lending and borrow thresholds, CEX
+// preference, alert rules and watchlist
+func loadChatSettings(chatID int64) chatSettings {
+	settings := chatSettings{
+		thresholds: getChatThresholds(chatID),
+		borrow:     getChatBorrowThresholds(chatID),
+		showCEX:    shouldShowCEXRates(chatID),
+		rateKind:   getChatRateKind(chatID),
+	}
+
+	rules, err := db.GetAlertRules(chatID)
+	if err != nil {
+		log.Printf("Error loading alert rules for chat %d: %v", chatID, err)
+	}
+	settings.rules = append(rules, thresholdRules(settings.thresholds)...)
+	settings.rules = append(settings.rules, borrowThresholdRules(settings.borrow)...)
+
+	tokens, err := db.GetSubscriptions(chatID)
+	if err != nil {
+		log.Printf("Error loading subscriptions for chat %d: %v", chatID, err)
+		return settings
+	}
+	if len(tokens) > 0 {
+		settings.watched = make(map[string]bool, len(tokens))
+		for _, token := range tokens {
+			settings.watched[token] = true
+		}
+	}
+	return settings
+}
+
+// isWatched reports whether the chat wants notifications for a token
+func (s chatSettings) isWatched(token string) bool {
+	return s.watched == nil || s.watched[token]
+}
+
+// showsSource reports whether the chat displays a source's rates, so it
+// should hear about the source's status changes. Sources that never returned
+// rates cannot be filtered and are shown to everyone.
+func (s chatSettings) showsSource(health SourceHealth) bool {
+	if health.Category == "CEX" && !s.showCEX {
+		return false
+	}
+	if len(health.Tokens) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(health.Tokens, s.isWatched)
+}
+
+// sourceChangesFor filters status changes down to the sources a chat displays
+func sourceChangesFor(changes []SourceStatusChange, settings chatSettings) []SourceStatusChange {
+	var shown []SourceStatusChange
+	for _, change := range changes {
+		if settings.showsSource(change.Health) {
+			shown = append(shown, change)
+		}
+	}
+	return shown
+}
+
+// formatRateAlert builds the notification message listing every rate of the
+// tokens that triggered an alert, followed by the rules that fired
+func formatRateAlert(matches []AlertMatch, rates []Rate, settings chatSettings) string {
+	tokensWithHighRates := make(map[string]bool)
+	matchesByToken := make(map[string][]AlertMatch)
+	for _, match := range matches {
+		tokensWithHighRates[match.Rate.Token] = true
+		matchesByToken[match.Rate.Token] = append(matchesByToken[match.Rate.Token], match)
+	}
+
+	ratesByToken := make(map[string][]Rate)
+	for _, rate := range rates {
+		if !tokensWithHighRates[rate.Token] {
+			continue
+		}
+		if rate.Category == "CEX" && !settings.showCEX {
+			continue
+		}
+		ratesByToken[rate.Token] = append(ratesByToken[rate.Token], rate)
+	}
+
+	// Sort tokens for consistent ordering
+	var tokens []string
+	for token := range ratesByToken {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+
+	var message strings.Builder
+	for _, token := range tokens {
+		tokenRates := ratesByToken[token]
+		message.WriteString(fmt.Sprintf("🪙 *%s*\n", token))
+
+		// Sort rates by source for consistent ordering
+		sort.Slice(tokenRates, func(i, j int) bool {
+			return tokenRates[i].Source < tokenRates[j].Source
+		})
+
+		for _, rate := range tokenRates {
+			rate = normalizeRate(rate, settings.rateKind)
+			message.WriteString(formatRate(rate, settings.thresholds[token]))
+			message.WriteString("\n")
+		}
+		for _, match := range matchesByToken[token] {
+			message.WriteString(formatAlertReason(match, settings.rateKind))
+			message.WriteString("\n")
+		}
+		message.WriteString("\n")
+	}
+	return message.String()
+}
+
+// formatAlertReason describes which rule a rate triggered, showing the rate
+// in the given kind
+func formatAlertReason(match AlertMatch, kind RateKind) string {
+	label := "threshold"
+	if match.Rule.ID != 0 {
+		label = fmt.Sprintf("alert #%d", match.Rule.ID)
+	}
+	return fmt.Sprintf("🔔 %s %s %.1f%% (%s: `%s`)",
+		match.Rate.Source, match.Rule.Field, match.Rule.value(normalizeRate(match.Rate, kind)), label, match.Rule)
+}
+
+// formatDropAlert builds the notification message for falling rates
+func formatDropAlert(drops []RateDrop, kind RateKind) string {
+	dropsByToken := make(map[string][]RateDrop)
+	for _, drop := range drops {
+		dropsByToken[drop.Rate.Token] = append(dropsByToken[drop.Rate.Token], drop)
+	}
+
+	// Sort tokens for consistent ordering
+	var tokens []string
+	for token := range dropsByToken {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+
+	var message strings.Builder
+	message.WriteString("📉 *Lending rates falling*\n")
+	for _, token := range tokens {
+		tokenDrops := dropsByToken[token]
+		message.WriteString(fmt.Sprintf("🪙 *%s*\n", token))
+
+		// Sort drops by source for consistent ordering
+		sort.Slice(tokenDrops, func(i, j int) bool {
+			return tokenDrops[i].Rate.Source < tokenDrops[j].Rate.Source
+		})
+
+		for _, drop := range tokenDrops {
+			note := fmt.Sprintf("-%.0f%%", drop.DropPercent)
+			if drop.CrossedBelow {
+				note += fmt.Sprintf(", below %.0f%%", drop.Threshold)
+			}
+			message.WriteString(fmt.Sprintf("`%-8s%5.0f%% →%5.0f%%` %s\n",
+				drop.Rate.Source, normalizeRate(drop.Previous, kind).LendingRate,
+				normalizeRate(drop.Rate, kind).LendingRate, note))
+		}
+		message.WriteString("\n")
+	}
+	return message.String()
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChatSettings_IsWatched(t *testing.T) {
+	all := chatSettings{}
+	if !all.isWatched("USDT") {
+		t.Error("isWatched() without a watchlist should match every token")
+	}
+
+	limited := chatSettings{watched: map[string]bool{"USDC": true}}
+	if limited.isWatched("USDT") || !limited.isWatched("USDC") {
+		t.Error("isWatched() should only match tokens in the watchlist")
+	}
+}
+
+func TestFormatRateAlert(t *testing.T) {
+	rates := []Rate{
+		{Source: "Neptune", Token: "USDT", Category: "DEX", LendingRate: 35},
+		{Source: "OKX", Token: "USDT", Category: "CEX", LendingRate: 12},
+		{Source: "Neptune", Token: "USDC", Category: "DEX", LendingRate: 10},
+	}
+	matches := []AlertMatch{{
+		Rule: AlertRule{ID: 7, Token: "USDT", Field: AlertFieldLend, Operator: ">", Value: 25},
+		Rate: rates[0],
+	}}
+	settings := chatSettings{thresholds: map[string]float64{"USDT": 30, "USDC": 30}, showCEX: true}
+
+	message := formatRateAlert(matches, rates, settings)
+	if !strings.Contains(message, "USDT") || !strings.Contains(message, "OKX") {
+		t.Errorf("formatRateAlert() should list every USDT source, got:\n%s", message)
+	}
+	if strings.Contains(message, "USDC") {
+		t.Errorf("formatRateAlert() should only include triggered tokens, got:\n%s", message)
+	}
+	if !strings.Contains(message, "alert #7") {
+		t.Errorf("formatRateAlert() should name the triggered rule, got:\n%s", message)
+	}
+
+	apy := AlertMatch{Rule: matches[0].Rule, Rate: Rate{Source: "Neptune", Token: "USDT",
+		LendingRate: 45, Kind: RateKindAPY, CompoundsPerYear: 365}}
+	if reason := formatAlertReason(apy, RateKindAPR); !strings.Contains(reason, "37.2%") {
+		t.Errorf("formatAlertReason() should show the rate as APR, got: %s", reason)
+	}
+
+	settings.showCEX = false
+	message = formatRateAlert(matches, rates, settings)
+	if strings.Contains(message, "OKX") {
+		t.Errorf("formatRateAlert() should hide CEX rates, got:\n%s", message)
+	}
+}
+
+func TestFormatDropAlert(t *testing.T) {
+	drops := []RateDrop{{
+		Rate:         Rate{Source: "Injera", Token: "USDT", LendingRate: 5, Kind: RateKindAPY, CompoundsPerYear: 365},
+		Previous:     Rate{Source: "Injera", Token: "USDT", LendingRate: 45, Kind: RateKindAPY, CompoundsPerYear: 365},
+		Threshold:    30,
+		CrossedBelow: true,
+		DropPercent:  88.9,
+	}}
+
+	message := formatDropAlert(drops, RateKindAPY)
+	for _, want := range []string{"USDT", "Injera", "45%", "5%", "-89%", "below 30%"} {
+		if !strings.Contains(message, want) {
+			t.Errorf("formatDropAlert() missing %q in:\n%s", want, message)
+		}
+	}
+
+	message = formatDropAlert(drops, RateKindAPR)
+	if !strings.Contains(message, "37%") {
+		t.Errorf("formatDropAlert() should show rates as APR, got:\n%s", message)
+	}
+}
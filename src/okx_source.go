@@ -1,16 +1,32 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"time"
 )
 
+// knownOKXCurrencyIDs seeds the symbol cache so the default tokens resolve
+// without a discovery request
+var knownOKXCurrencyIDs = map[string]int{
+	"TIA":  2854,
+	"USDT": 7,
+	"USDC": 283,
+}
+
 type OKXSource struct {
-	APIURLTemplate string
-	CurrencyIDs    []int
-	Category       string
+	APIURLTemplate  string
+	CurrencyListURL string
+	Tokens          []string // Symbols resolved to currency IDs through discovery
+	CurrencyIDs     []int    // Currency IDs fetched in addition to Tokens
+	Category        string
+	Convention      RateConvention
+
+	currencies symbolCache[int]
 }
 
 type OKXResponse struct {
@@ -24,41 +40,119 @@ type OKXResponse struct {
 	} `json:"data"`
 }
 
+// OKXCurrencyListResponse lists the currencies available for lending
+type OKXCurrencyListResponse struct {
+	Data struct {
+		List []struct {
+			CurrencyID   int    `json:"currencyId"`
+			CurrencyName string `json:"currencyName"`
+		} `json:"list"`
+	} `json:"data"`
+}
+
 func NewOKXSource() *OKXSource {
 	return &OKXSource{
-		APIURLTemplate: "https://www.okx.com/priapi/v2/financial/market-lending-info?currencyId=%d",
-		CurrencyIDs:    []int{2854, 7, 283}, // TIA, USDT, USDC
-		Category:       "CEX",
+		APIURLTemplate:  "https://www.okx.com/priapi/v2/financial/market-lending-info?currencyId=%d",
+		CurrencyListURL: "https://www.okx.com/priapi/v2/financial/market-lending-list",
+		Tokens:          []string{"TIA", "USDT", "USDC"},
+		Category:        "CEX",
+		Convention:      hourlyAPY, // Lending interest is paid hourly
+		currencies:      symbolCache[int]{name: "OKX currencies", seed: knownOKXCurrencyIDs},
 	}
 }
 
-func (s *OKXSource) FetchRates() ([]Rate, error) {
+func (s *OKXSource) Name() string {
+	return "OKX"
+}
+
+// FetchRates fetches each currency separately. Currencies that fail are
+// logged and skipped; if some succeed they are returned in a PartialError.
+func (s *OKXSource) FetchRates(ctx context.Context) ([]Rate, error) {
+	var discover func(context.Context) (map[string]int, error)
+	if s.CurrencyListURL != "" {
+		discover = s.discoverCurrencies
+	}
+	currencyIDs, unknown := s.currencies.resolve(ctx, s.Tokens, s.CurrencyIDs, discover)
+
+	var errs []error
+	for _, token := range unknown {
+		errs = append(errs, fmt.Errorf("unknown OKX currency %s", token))
+	}
+
 	var rates []Rate
-	for _, currencyID := range s.CurrencyIDs {
-		estimatedRate, preRate, _, currencyName, err := s.fetchInterestRates(currencyID)
+	for _, currencyID := range currencyIDs {
+		estimatedRate, preRate, _, currencyName, err := s.fetchInterestRates(ctx, currencyID)
 		if err != nil {
-			return nil, fmt.Errorf("error fetching interest rates for currency ID %d: %v", currencyID, err)
+			err = fmt.Errorf("error fetching interest rates for currency ID %d: %w", currencyID, err)
+			log.Printf("Error fetching OKX rates: %v", err)
+			errs = append(errs, err)
+			continue
 		}
 
-		rates = append(rates, Rate{
-			Source:      "OKX",
-			Token:       currencyName,
-			BorrowRate:  preRate * 100,       // Assuming preRate is the borrow rate
-			LendingRate: estimatedRate * 100, // Assuming estimatedRate is the lending rate
-			Category:    s.Category,
-		})
+		rates = append(rates, s.Convention.apply(Rate{
+			Source:         "OKX",
+			Token:          currencyName,
+			BorrowRate:     preRate * 100,       // Assuming preRate is the borrow rate
+			LendingRate:    estimatedRate * 100, // Assuming estimatedRate is the lending rate
+			Category:       s.Category,
+			FetchedAt:      time.Now(),
+			RawBorrowRate:  preRate * 100,
+			RawLendingRate: estimatedRate * 100,
+			SourceURL:      fmt.Sprintf(s.APIURLTemplate, currencyID),
+		}))
+	}
+
+	return partialResult(rates, errs)
+}
+
+// discoverCurrencies maps every currency available for lending to its ID
+func (s *OKXSource) discoverCurrencies(ctx context.Context) (map[string]int, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", s.CurrencyListURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponseStatus(resp); err != nil {
+		return nil, err
 	}
-	return rates, nil
+
+	var listResp OKXCurrencyListResponse
+	if err := json.NewDecoder(resp.Body).Decode(&listResp); err != nil {
+		return nil, err
+	}
+
+	currencies := make(map[string]int, len(listResp.Data.List))
+	for _, currency := range listResp.Data.List {
+		if currency.CurrencyName != "" {
+			currencies[currency.CurrencyName] = currency.CurrencyID
+		}
+	}
+	return currencies, nil
 }
 
-func (s *OKXSource) fetchInterestRates(currencyID int) (float64, float64, float64, string, error) {
+func (s *OKXSource) fetchInterestRates(ctx context.Context, currencyID int) (float64, float64, float64, string, error) {
 	url := fmt.Sprintf(s.APIURLTemplate, currencyID)
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return 0, 0, 0, "", err
+	}
+
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return 0, 0, 0, "", err
 	}
 	defer resp.Body.Close()
 
+	if err := checkResponseStatus(resp); err != nil {
+		return 0, 0, 0, "", err
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return 0, 0, 0, "", err
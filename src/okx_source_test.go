@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -66,7 +69,7 @@ func TestOKXSource_FetchRates(t *testing.T) {
 				CurrencyIDs:    []int{2854},
 			}
 
-			updates, err := source.FetchRates()
+			updates, err := source.FetchRates(context.Background())
 
 			if (err != nil) != tt.expectError {
 				t.Errorf("FetchRates() error = %v, expectError %v", err, tt.expectError)
@@ -100,3 +103,74 @@ func TestOKXSource_FetchRates(t *testing.T) {
 		})
 	}
 }
+
+func TestOKXSource_DiscoversCurrencies(t *testing.T) {
+	var listCalls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/list" {
+			listCalls++
+			w.Write([]byte(`{"data": {"list": [{"currencyId": 7, "currencyName": "USDT"}, {"currencyId": 1, "currencyName": "BTC"}]}}`))
+			return
+		}
+		switch r.URL.Query().Get("currencyId") {
+		case "1":
+			w.Write([]byte(`{"data": {"list": [{"currencyName": "BTC", "estimatedRate": 0.01, "preRate": 0.02}]}}`))
+		case "7":
+			w.Write([]byte(`{"data": {"list": [{"currencyName": "USDT", "estimatedRate": 0.05, "preRate": 0.06}]}}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	source := NewOKXSource()
+	source.APIURLTemplate = server.URL + "/info?currencyId=%d"
+	source.CurrencyListURL = server.URL + "/list"
+	source.Tokens = []string{"btc", "USDT", "DOGE"}
+
+	for i := 0; i < 2; i++ {
+		_, err := source.FetchRates(context.Background())
+		var partial *PartialError
+		if !errors.As(err, &partial) || !strings.Contains(err.Error(), "DOGE") {
+			t.Fatalf("FetchRates() error = %v, want DOGE reported as unknown", err)
+		}
+		rates := partial.Rates
+		if len(rates) != 2 || rates[0].Token != "BTC" || rates[1].Token != "USDT" {
+			t.Errorf("FetchRates() = %+v, want BTC and USDT", rates)
+		}
+	}
+
+	// DOGE stays unknown, but the list is not fetched again within the
+	// discovery interval
+	if listCalls != 1 {
+		t.Errorf("currency list fetched %d times, want 1", listCalls)
+	}
+}
+
+func TestOKXSource_PartialResults(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("currencyId") == "283" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{"data": {"list": [{"currencyName": "USDT", "estimatedRate": 0.05}]}}`))
+	}))
+	defer server.Close()
+
+	source := NewOKXSource()
+	source.APIURLTemplate = server.URL + "?currencyId=%d"
+	source.CurrencyListURL = ""
+	source.Tokens = []string{"USDT", "USDC"}
+
+	_, err := source.FetchRates(context.Background())
+	var partial *PartialError
+	if !errors.As(err, &partial) {
+		t.Fatalf("FetchRates() error = %v, want a partial error", err)
+	}
+	if len(partial.Rates) != 1 || partial.Rates[0].Token != "USDT" {
+		t.Errorf("FetchRates() partial rates = %+v, want only USDT", partial.Rates)
+	}
+	if !strings.Contains(partial.Error(), "283") {
+		t.Errorf("FetchRates() error = %v, want the failed currency named", partial)
+	}
+}
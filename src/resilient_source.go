@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned while a source's circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// HTTPStatusError is returned by sources when an endpoint answers with a
+// non-200 status code
+type HTTPStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d, body: %s", e.StatusCode, e.Body)
+}
+
+// PartialError is returned by sources that fetched only some of their rates.
+// Rates holds the ones that succeeded; Err describes the ones that failed.
+type PartialError struct {
+	Rates []Rate
+	Err   error
+}
+
+func (e *PartialError) Error() string {
+	return fmt.Sprintf("%d rates fetched, others failed: %v", len(e.Rates), e.Err)
+}
+
+func (e *PartialError) Unwrap() error {
+	return e.Err
+}
+
+// partialResult combines the outcome of a fetch made item by item: the rates
+// if every item succeeded, a PartialError if some failed, or the joined errors
+// if none succeeded
+func partialResult(rates []Rate, errs []error) ([]Rate, error) {
+	if len(errs) == 0 {
+		return rates, nil
+	}
+	if len(rates) == 0 {
+		return nil, errors.Join(errs...)
+	}
+	return nil, &PartialError{Rates: rates, Err: errors.Join(errs...)}
+}
+
+// checkResponseStatus turns non-200 responses into an HTTPStatusError
+func checkResponseStatus(resp *http.Response) error {
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return &HTTPStatusError{StatusCode: resp.StatusCode, Body: string(body)}
+}
+
+// isRetryable reports whether an error is transient: a network failure, a
+// per-attempt timeout, or a 5xx/429 response
+func isRetryable(err error) bool {
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500 || statusErr.StatusCode == http.StatusTooManyRequests
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
+
+// BreakerState is the state of a source's circuit breaker
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// ResilientSource wraps a RateSource with jittered exponential backoff
+// retries for transient errors and a circuit breaker that stops calling the
+// source for OpenDuration after FailureThreshold consecutive failed fetches
+type ResilientSource struct {
+	source           RateSource
+	MaxAttempts      int
+	BaseDelay        time.Duration
+	MaxDelay         time.Duration
+	FailureThreshold int
+	OpenDuration     time.Duration
+
+	mu                  sync.Mutex
+	state               BreakerState
+	consecutiveFailures int
+	openedAt            time.Time
+	now                 func() time.Time
+}
+
+func NewResilientSource(source RateSource) *ResilientSource {
+	return &ResilientSource{
+		source:           source,
+		MaxAttempts:      3,
+		BaseDelay:        500 * time.Millisecond,
+		MaxDelay:         5 * time.Second,
+		FailureThreshold: 5,
+		OpenDuration:     10 * time.Minute,
+		now:              time.Now,
+	}
+}
+
+func (r *ResilientSource) Name() string {
+	return r.source.Name()
+}
+
+// UnknownDenoms forwards the wrapped source's unknown denoms, if it reports any
+func (r *ResilientSource) UnknownDenoms() []string {
+	if reporter, ok := r.source.(denomReporter); ok {
+		return reporter.UnknownDenoms()
+	}
+	return nil
+}
+
+// BreakerState returns the current circuit breaker state
+func (r *ResilientSource) BreakerState() BreakerState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.state == BreakerOpen && r.now().Sub(r.openedAt) >= r.OpenDuration {
+		return BreakerHalfOpen
+	}
+	return r.state
+}
+
+func (r *ResilientSource) FetchRates(ctx context.Context) ([]Rate, error) {
+	if !r.allow() {
+		return nil, ErrCircuitOpen
+	}
+
+	var err error
+	for attempt := 0; attempt < r.MaxAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(r.backoff(attempt)):
+			case <-ctx.Done():
+				r.recordFailure()
+				return nil, fmt.Errorf("%w (last error: %v)", ctx.Err(), err)
+			}
+		}
+
+		var rates []Rate
+		rates, err = r.source.FetchRates(ctx)
+		if err == nil {
+			r.recordSuccess()
+			return rates, nil
+		}
+
+		// A source that returned some rates is reachable, so its breaker
+		// stays closed, and retrying would fetch the rates it got again
+		var partial *PartialError
+		if errors.As(err, &partial) {
+			r.recordSuccess()
+			return nil, err
+		}
+		if !isRetryable(err) || ctx.Err() != nil {
+			break
+		}
+		log.Printf("Retrying %s after attempt %d failed: %v", r.Name(), attempt+1, err)
+	}
+
+	r.recordFailure()
+	return nil, err
+}
+
+// backoff returns the jittered delay before the given retry attempt
+func (r *ResilientSource) backoff(attempt int) time.Duration {
+	delay := r.BaseDelay << (attempt - 1)
+	if delay > r.MaxDelay || delay <= 0 {
+		delay = r.MaxDelay
+	}
+	// Full jitter in [delay/2, delay) spreads retries from concurrent sources
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// allow reports whether a fetch may proceed, moving an expired open breaker
+// to half-open so that a single trial fetch goes through
+func (r *ResilientSource) allow() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch r.state {
+	case BreakerOpen:
+		if r.now().Sub(r.openedAt) < r.OpenDuration {
+			return false
+		}
+		r.state = BreakerHalfOpen
+		log.Printf("Circuit breaker for %s is half-open, trying a fetch", r.Name())
+		return true
+	case BreakerHalfOpen:
+		// Only one trial fetch at a time
+		return false
+	default:
+		return true
+	}
+}
+
+func (r *ResilientSource) recordSuccess() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.state != BreakerClosed {
+		log.Printf("Circuit breaker for %s closed", r.Name())
+	}
+	r.state = BreakerClosed
+	r.consecutiveFailures = 0
+}
+
+func (r *ResilientSource) recordFailure() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.consecutiveFailures++
+	if r.state == BreakerHalfOpen || r.consecutiveFailures >= r.FailureThreshold {
+		if r.state != BreakerOpen {
+			log.Printf("Circuit breaker for %s opened after %d consecutive failures",
+				r.Name(), r.consecutiveFailures)
+		}
+		r.state = BreakerOpen
+		r.openedAt = r.now()
+	}
+}
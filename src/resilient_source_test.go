@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// flakySource fails with the queued errors before succeeding
+type flakySource struct {
+	errs  []error
+	calls int
+}
+
+func (s *flakySource) Name() string {
+	return "Flaky"
+}
+
+func (s *flakySource) FetchRates(ctx context.Context) ([]Rate, error) {
+	s.calls++
+	if len(s.errs) > 0 {
+		err := s.errs[0]
+		s.errs = s.errs[1:]
+		return nil, err
+	}
+	return []Rate{{Source: "Flaky", Token: "USDT", LendingRate: 10}}, nil
+}
+
+func newTestResilientSource(source RateSource) *ResilientSource {
+	resilient := NewResilientSource(source)
+	resilient.BaseDelay = time.Millisecond
+	resilient.MaxDelay = 2 * time.Millisecond
+	return resilient
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"server error", &HTTPStatusError{StatusCode: 502}, true},
+		{"rate limited", &HTTPStatusError{StatusCode: 429}, true},
+		{"wrapped server error", fmt.Errorf("fetching: %w", &HTTPStatusError{StatusCode: 503}), true},
+		{"client error", &HTTPStatusError{StatusCode: 404}, false},
+		{"network error", fmt.Errorf("fetching: %w", &net.OpError{Op: "dial", Err: errors.New("refused")}), true},
+		{"parse error", errors.New("invalid character"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryable(tt.err); got != tt.want {
+				t.Errorf("isRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResilientSource_Retries(t *testing.T) {
+	source := &flakySource{errs: []error{
+		&HTTPStatusError{StatusCode: 503},
+		&HTTPStatusError{StatusCode: 502},
+	}}
+	resilient := newTestResilientSource(source)
+
+	rates, err := resilient.FetchRates(context.Background())
+	if err != nil {
+		t.Fatalf("FetchRates() error = %v", err)
+	}
+	if len(rates) != 1 || source.calls != 3 {
+		t.Errorf("FetchRates() = %d rates after %d calls, want 1 rate after 3 calls", len(rates), source.calls)
+	}
+
+	// Permanent errors are not retried
+	source = &flakySource{errs: []error{errors.New("invalid JSON")}}
+	resilient = newTestResilientSource(source)
+	if _, err := resilient.FetchRates(context.Background()); err == nil {
+		t.Error("FetchRates() should return the permanent error")
+	}
+	if source.calls != 1 {
+		t.Errorf("FetchRates() made %d calls for a permanent error, want 1", source.calls)
+	}
+}
+
+func TestResilientSource_CircuitBreaker(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	failure := errors.New("invalid JSON")
+	source := &flakySource{}
+	resilient := newTestResilientSource(source)
+	resilient.FailureThreshold = 2
+	resilient.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		source.errs = []error{failure}
+		if _, err := resilient.FetchRates(context.Background()); err == nil {
+			t.Fatal("FetchRates() should fail")
+		}
+	}
+	if state := resilient.BreakerState(); state != BreakerOpen {
+		t.Fatalf("BreakerState() = %v, want open", state)
+	}
+
+	// While open the source is not called
+	calls := source.calls
+	if _, err := resilient.FetchRates(context.Background()); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("FetchRates() error = %v, want ErrCircuitOpen", err)
+	}
+	if source.calls != calls {
+		t.Error("FetchRates() called the source while the breaker was open")
+	}
+
+	// After the open duration a failed trial reopens the breaker
+	now = now.Add(resilient.OpenDuration)
+	if state := resilient.BreakerState(); state != BreakerHalfOpen {
+		t.Errorf("BreakerState() = %v, want half-open", state)
+	}
+	source.errs = []error{failure}
+	if _, err := resilient.FetchRates(context.Background()); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("FetchRates() trial error = %v, want source error", err)
+	}
+	if state := resilient.BreakerState(); state != BreakerOpen {
+		t.Fatalf("BreakerState() after failed trial = %v, want open", state)
+	}
+
+	// A successful trial closes it again
+	now = now.Add(resilient.OpenDuration)
+	if _, err := resilient.FetchRates(context.Background()); err != nil {
+		t.Fatalf("FetchRates() trial error = %v", err)
+	}
+	if state := resilient.BreakerState(); state != BreakerClosed {
+		t.Errorf("BreakerState() after successful trial = %v, want closed", state)
+	}
+}
+
+func TestResilientSource_PartialResults(t *testing.T) {
+	source := &flakySource{}
+	resilient := newTestResilientSource(source)
+	resilient.FailureThreshold = 2
+
+	// Partial results are returned without retrying, even when the failed
+	// part is transient
+	for i := 0; i < 3; i++ {
+		source.errs = []error{&PartialError{Rates: []Rate{{Token: "USDT"}}, Err: &HTTPStatusError{StatusCode: 503}}}
+		calls := source.calls
+		_, err := resilient.FetchRates(context.Background())
+		var partial *PartialError
+		if !errors.As(err, &partial) || len(partial.Rates) != 1 {
+			t.Fatalf("FetchRates() error = %v, want the partial error passed through", err)
+		}
+		if source.calls != calls+1 {
+			t.Errorf("FetchRates() called the source %d times, want once", source.calls-calls)
+		}
+	}
+	if state := resilient.BreakerState(); state != BreakerClosed {
+		t.Errorf("BreakerState() after partial results = %v, want closed", state)
+	}
+}
+
+func TestCheckResponseStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "maintenance", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	source := NewNeptuneSource()
+	source.APIURL = server.URL
+
+	_, err := source.FetchRates(context.Background())
+	var statusErr *HTTPStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("FetchRates() error = %v, want HTTPStatusError 503", err)
+	}
+	if !isRetryable(err) {
+		t.Error("isRetryable() should retry a 503 from a source")
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// An empty watchlist means all tokens, so these removals are refused rather
+// than silently widening or keeping the subscription
+const (
+	unwatchLastTokenMessage = "You can't unwatch the last token on your watchlist, since an empty watchlist means all tokens. Use /watch all to get notifications for every token, or /stop to unsubscribe."
+	unwatchAllTokensMessage = "You are watching all tokens. Use /watch <tokens...> to only get notifications for specific tokens."
+)
+
+// parseTokens normalises command arguments like "usdt, USDC" into symbols
+func parseTokens(args []string) []string {
+	var tokens []string
+	seen := make(map[string]bool)
+	for _, arg := range args {
+		for _, token := range strings.Split(arg, ",") {
+			token = strings.ToUpper(strings.TrimSpace(token))
+			if token == "" || seen[token] {
+				continue
+			}
+			seen[token] = true
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+// watchTokens subscribes a chat to notifications for the given tokens
+func watchTokens(chatID int64, tokens []string) error {
+	for _, token := range tokens {
+		if err := db.AddSubscription(chatID, token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// planUnwatch returns the watchlist left after removing tokens from watched.
+// Removals that would leave the watchlist empty are refused with a message
+// for the user.
+func planUnwatch(watched, tokens []string) ([]string, string) {
+	if len(watched) == 0 {
+		return nil, unwatchAllTokensMessage
+	}
+	var remaining []string
+	for _, token := range watched {
+		if !slices.Contains(tokens, token) {
+			remaining = append(remaining, token)
+		}
+	}
+	if len(remaining) == 0 {
+		return nil, unwatchLastTokenMessage
+	}
+	return remaining, ""
+}
+
+// formatWatchlist describes which tokens a chat receives notifications for
+func formatWatchlist(tokens []string) string {
+	if len(tokens) == 0 {
+		return "You are watching all tokens. Use /watch <tokens...> to only get notifications for specific tokens."
+	}
+	return fmt.Sprintf("You are watching: %s", strings.Join(tokens, ", "))
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTokens(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"no arguments", nil, nil},
+		{"separate arguments", []string{"usdt", "USDC"}, []string{"USDT", "USDC"}},
+		{"comma separated", []string{"usdt,usdc,", "tia"}, []string{"USDT", "USDC", "TIA"}},
+		{"duplicates", []string{"USDT", "usdt"}, []string{"USDT"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseTokens(tt.args)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("parseTokens(%v) = %v, want %v", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanUnwatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		watched []string
+		tokens  []string
+		want    []string
+		refusal string
+	}{
+		{"remove one", []string{"TIA", "USDT"}, []string{"TIA"}, []string{"USDT"}, ""},
+		{"not watched", []string{"USDT"}, []string{"TIA"}, []string{"USDT"}, ""},
+		{"last token", []string{"USDT"}, []string{"USDT"}, nil, unwatchLastTokenMessage},
+		{"every token", []string{"TIA", "USDT"}, []string{"USDT", "TIA"}, nil, unwatchLastTokenMessage},
+		{"watching all", nil, []string{"USDT"}, nil, unwatchAllTokensMessage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, refusal := planUnwatch(tt.watched, tt.tokens)
+			if refusal != tt.refusal {
+				t.Fatalf("planUnwatch() refusal = %q, want %q", refusal, tt.refusal)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("planUnwatch() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
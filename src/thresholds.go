@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// getChatThresholds returns the lending thresholds for a chat, with the
+// chat's own settings overriding the global defaults
+func getChatThresholds(chatID int64) map[string]float64 {
+	configMutex.RLock()
+	thresholds := make(map[string]float64, len(lendingThresholds))
+	for token, threshold := range lendingThresholds {
+		thresholds[token] = threshold
+	}
+	configMutex.RUnlock()
+
+	overrides, err := db.GetThresholds(chatID)
+	if err != nil {
+		log.Printf("Error loading thresholds for chat %d: %v", chatID, err)
+		return thresholds
+	}
+	for token, threshold := range overrides {
+		thresholds[token] = threshold
+	}
+	return thresholds
+}
+
+// BorrowThreshold bounds a token's borrow rate. A zero Ceiling or Floor
+// disables that side.
+type BorrowThreshold struct {
+	Ceiling float64 `yaml:"ceiling"`
+	Floor   float64 `yaml:"floor"`
+}
+
+// getChatBorrowThresholds returns the borrow bounds for a chat, with the
+// chat's own settings overriding the global defaults
+func getChatBorrowThresholds(chatID int64) map[string]BorrowThreshold {
+	configMutex.RLock()
+	thresholds := make(map[string]BorrowThreshold, len(borrowThresholds))
+	for token, threshold := range borrowThresholds {
+		thresholds[token] = threshold
+	}
+	configMutex.RUnlock()
+
+	overrides, err := db.GetBorrowThresholds(chatID)
+	if err != nil {
+		log.Printf("Error loading borrow thresholds for chat %d: %v", chatID, err)
+		return thresholds
+	}
+	for token, threshold := range overrides {
+		thresholds[token] = threshold
+	}
+	return thresholds
+}
+
+// parsePercent parses values like "25", "25%" or "12.5"
+func parsePercent(s string) (float64, error) {
+	value, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, fmt.Errorf("invalid percentage: %s", s)
+	}
+	if value < 0 {
+		return 0, fmt.Errorf("percentage must not be negative: %s", s)
+	}
+	return value, nil
+}
+
+// formatThresholds lists a chat's thresholds, marking the ones it customised
+func formatThresholds(thresholds map[string]float64, overrides map[string]float64) string {
+	var tokens []string
+	for token := range thresholds {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+
+	var message strings.Builder
+	message.WriteString("*Lending Thresholds*\n")
+	for _, token := range tokens {
+		marker := ""
+		if _, custom := overrides[token]; custom {
+			marker = " ✏️"
+		}
+		message.WriteString(fmt.Sprintf("`%-8s%6.1f%%`%s\n", token, thresholds[token], marker))
+	}
+	message.WriteString("\n✏️ = custom, others use the default")
+	return message.String()
+}
+
+// formatBorrowThresholds lists a chat's borrow bounds, marking the ones it customised
+func formatBorrowThresholds(thresholds map[string]BorrowThreshold, overrides map[string]BorrowThreshold) string {
+	if len(thresholds) == 0 {
+		return "*Borrow Thresholds*\nNone set. Example: /threshold USDT borrow above 20"
+	}
+
+	var tokens []string
+	for token := range thresholds {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+
+	formatBound := func(value float64) string {
+		if value == 0 {
+			return "-"
+		}
+		return fmt.Sprintf("%.1f%%", value)
+	}
+
+	var message strings.Builder
+	message.WriteString("*Borrow Thresholds*\n")
+	message.WriteString(fmt.Sprintf("`%-8s%7s%7s`\n", "", "above", "below"))
+	for _, token := range tokens {
+		marker := ""
+		if _, custom := overrides[token]; custom {
+			marker = " ✏️"
+		}
+		threshold := thresholds[token]
+		message.WriteString(fmt.Sprintf("`%-8s%7s%7s`%s\n", token,
+			formatBound(threshold.Ceiling), formatBound(threshold.Floor), marker))
+	}
+	return message.String()
+}
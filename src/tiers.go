@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sortTiers orders tiers by their lower bound
+func sortTiers(tiers []RateTier) {
+	sort.Slice(tiers, func(i, j int) bool {
+		return tiers[i].MinAmount < tiers[j].MinAmount
+	})
+}
+
+// bestTierRate returns the highest lending rate among the tiers
+func bestTierRate(tiers []RateTier) float64 {
+	var best float64
+	for _, tier := range tiers {
+		best = max(best, tier.LendingRate)
+	}
+	return best
+}
+
+// parseTierBand parses amount bands such as "0-200USDT" or "5-10BTC"
+func parseTierBand(band string) (float64, float64, error) {
+	band = strings.TrimRightFunc(band, func(r rune) bool {
+		return r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z'
+	})
+	minStr, maxStr, ok := strings.Cut(band, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid tier band %q", band)
+	}
+	minAmount, err := strconv.ParseFloat(minStr, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid tier band %q: %w", band, err)
+	}
+	maxAmount, err := strconv.ParseFloat(maxStr, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid tier band %q: %w", band, err)
+	}
+	return minAmount, maxAmount, nil
+}
+
+// formatAmount renders a deposit amount compactly, e.g. 500, 10k or 1.5M
+func formatAmount(amount float64) string {
+	switch {
+	case amount >= 1e6:
+		return strconv.FormatFloat(amount/1e6, 'f', -1, 64) + "M"
+	case amount >= 1e3:
+		return strconv.FormatFloat(amount/1e3, 'f', -1, 64) + "k"
+	default:
+		return strconv.FormatFloat(amount, 'f', -1, 64)
+	}
+}
+
+// formatTiers lists a rate's deposit bands below its row in /rate
+func formatTiers(tiers []RateTier) string {
+	var message strings.Builder
+	for _, tier := range tiers {
+		band := fmt.Sprintf("%s-%s", formatAmount(tier.MinAmount), formatAmount(tier.MaxAmount))
+		if tier.MaxAmount == 0 {
+			band = fmt.Sprintf(">%s", formatAmount(tier.MinAmount))
+		}
+		message.WriteString(fmt.Sprintf("`  %-13s%5.1f%%`\n", band, tier.LendingRate))
+	}
+	return message.String()
+}
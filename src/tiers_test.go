@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTierBand(t *testing.T) {
+	tests := []struct {
+		band    string
+		wantMin float64
+		wantMax float64
+		wantErr bool
+	}{
+		{"0-200USDT", 0, 200, false},
+		{"5-10BTC", 5, 10, false},
+		{"200USDT", 0, 0, true},
+		{"a-bUSDT", 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		minAmount, maxAmount, err := parseTierBand(tt.band)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseTierBand(%q) error = %v, wantErr %v", tt.band, err, tt.wantErr)
+			continue
+		}
+		if minAmount != tt.wantMin || maxAmount != tt.wantMax {
+			t.Errorf("parseTierBand(%q) = %v, %v, want %v, %v", tt.band, minAmount, maxAmount, tt.wantMin, tt.wantMax)
+		}
+	}
+}
+
+func TestBinanceTiers(t *testing.T) {
+	tiers := binanceTiers(2, map[string]string{"200-1000USDT": "0.01", "0-200USDT": "0.06", "bogus": "0.5"})
+	want := []RateTier{
+		{MinAmount: 0, MaxAmount: 200, LendingRate: 8},
+		{MinAmount: 200, MaxAmount: 1000, LendingRate: 3},
+		{MinAmount: 1000, LendingRate: 2},
+	}
+	if len(tiers) != len(want) {
+		t.Fatalf("binanceTiers() = %+v, want %+v", tiers, want)
+	}
+	for i := range want {
+		if tiers[i] != want[i] {
+			t.Errorf("binanceTiers()[%d] = %+v, want %+v", i, tiers[i], want[i])
+		}
+	}
+
+	if tiers := binanceTiers(2, nil); tiers != nil {
+		t.Errorf("binanceTiers() without bands = %+v, want nil", tiers)
+	}
+}
+
+func TestFormatTiers(t *testing.T) {
+	message := formatTiers([]RateTier{
+		{MinAmount: 0, MaxAmount: 500, LendingRate: 8},
+		{MinAmount: 500, MaxAmount: 100000, LendingRate: 4},
+		{MinAmount: 100000, LendingRate: 1.5},
+	})
+
+	for _, want := range []string{"0-500", "8.0%", "500-100k", ">100k", "1.5%"} {
+		if !strings.Contains(message, want) {
+			t.Errorf("formatTiers() missing %q in:\n%s", want, message)
+		}
+	}
+}
+
+func TestNormalizeRate_Tiers(t *testing.T) {
+	tiers := []RateTier{{MaxAmount: 500, LendingRate: 10}, {MinAmount: 500, LendingRate: 2}}
+	rate := dailyAPY.apply(Rate{LendingRate: 10, Tiers: tiers})
+
+	apr := normalizeRate(rate, RateKindAPR)
+	if apr.Tiers[0].LendingRate != convertAPYtoAPR(10, 365) {
+		t.Errorf("normalizeRate() tier = %v, want %v", apr.Tiers[0].LendingRate, convertAPYtoAPR(10, 365))
+	}
+	if tiers[0].LendingRate != 10 {
+		t.Error("normalizeRate() modified the original tiers")
+	}
+}
@@ -1,15 +1,54 @@
 package main
 
+import (
+	"context"
+	"time"
+)
+
+// RateKind says whether an annual rate is simple (APR) or compounded (APY)
+type RateKind string
+
+const (
+	RateKindAPR RateKind = "APR"
+	RateKindAPY RateKind = "APY"
+)
+
 type Rate struct {
-	Source      string  `json:"source"`
-	Token       string  `json:"token"`
-	BorrowRate  float64 `json:"borrow_rate"`
+	Source      string    `json:"source"`
+	Token       string    `json:"token"`
+	BorrowRate  float64   `json:"borrow_rate"`
+	LendingRate float64   `json:"lending_rate"`
+	Category    string    `json:"category"`
+	FetchedAt   time.Time `json:"fetched_at"`
+	Stale       bool      `json:"stale"` // Last known good rate served while its source is failing
+
+	// Provenance of the rate as reported by the source
+	Kind             RateKind `json:"kind"`               // Whether LendingRate and BorrowRate are APR or APY
+	CompoundsPerYear int      `json:"compounds_per_year"` // Compounding assumed when converting between APR and APY
+	RawLendingRate   float64  `json:"raw_lending_rate"`   // Lending rate in percent as reported, before conversion
+	RawBorrowRate    float64  `json:"raw_borrow_rate"`    // Borrow rate in percent as reported, before conversion
+	SourceURL        string   `json:"source_url"`
+
+	// Market depth for lending protocols that report it
+	Utilization float64 `json:"utilization,omitempty"`  // Share of supplied funds that is borrowed, in percent
+	TotalSupply float64 `json:"total_supply,omitempty"` // Supplied amount in token units
+
+	// Deposit bands with their own lending rates, in ascending order. When
+	// set, LendingRate is the best tier's rate.
+	Tiers []RateTier `json:"tiers,omitempty"`
+}
+
+// RateTier is the lending rate paid on the part of a deposit between
+// MinAmount and MaxAmount, in the same kind as its Rate. A zero MaxAmount
+// means the band has no upper bound.
+type RateTier struct {
+	MinAmount   float64 `json:"min_amount"`
+	MaxAmount   float64 `json:"max_amount"`
 	LendingRate float64 `json:"lending_rate"`
-	Category    string  `json:"category"`
 }
 
 type Source interface {
-	FetchRates() ([]Rate, error)
+	FetchRates(ctx context.Context) ([]Rate, error)
 }
 
 func GetSources() []Source {
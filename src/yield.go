@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// YieldEstimate is the interest a deposit would earn at one venue
+type YieldEstimate struct {
+	Rate         Rate
+	EffectiveAPY float64 // APY blended across the deposit's tiers
+	Daily        float64
+	Monthly      float64
+	Yearly       float64
+}
+
+// parseAmount parses deposit amounts such as "250000", "250,000", "250k" or
+// "1.5m"
+func parseAmount(input string) (float64, error) {
+	s := strings.ToLower(strings.ReplaceAll(input, ",", ""))
+	multiplier := 1.0
+	switch {
+	case strings.HasSuffix(s, "k"):
+		multiplier, s = 1e3, strings.TrimSuffix(s, "k")
+	case strings.HasSuffix(s, "m"):
+		multiplier, s = 1e6, strings.TrimSuffix(s, "m")
+	}
+
+	amount, err := strconv.ParseFloat(s, 64)
+	if err != nil || !(amount > 0) || math.IsInf(amount, 0) {
+		return 0, fmt.Errorf("invalid amount %q", input)
+	}
+	return amount * multiplier, nil
+}
+
+// effectiveRate blends a tiered rate over a deposit: each tier's rate applies
+// only to the part of the amount within its band. Amounts above a bounded top
+// tier earn nothing since the venue does not accept them. Rates without tiers
+// apply their lending rate to the whole amount.
+func effectiveRate(rate Rate, amount float64) float64 {
+	if len(rate.Tiers) == 0 || amount <= 0 {
+		return rate.LendingRate
+	}
+
+	var interest float64
+	for _, tier := range rate.Tiers {
+		upper := amount
+		if tier.MaxAmount > 0 {
+			upper = min(upper, tier.MaxAmount)
+		}
+		if upper > tier.MinAmount {
+			interest += (upper - tier.MinAmount) * tier.LendingRate
+		}
+	}
+	return interest / amount
+}
+
+// estimateYields projects the interest on a deposit of a token at every venue
+// quoting it, best first. Rates are expected to be APY.
+func estimateYields(rates []Rate, token string, amount float64) []YieldEstimate {
+	var estimates []YieldEstimate
+	for _, rate := range rates {
+		if rate.Token != token {
+			continue
+		}
+
+		apy := effectiveRate(rate, amount)
+		growth := 1 + apy/100
+		estimates = append(estimates, YieldEstimate{
+			Rate:         rate,
+			EffectiveAPY: apy,
+			Daily:        amount * (math.Pow(growth, 1.0/365) - 1),
+			Monthly:      amount * (math.Pow(growth, 1.0/12) - 1),
+			Yearly:       amount * apy / 100,
+		})
+	}
+
+	sort.SliceStable(estimates, func(i, j int) bool {
+		return estimates[i].EffectiveAPY > estimates[j].EffectiveAPY
+	})
+	return estimates
+}
+
+// formatMoney renders interest amounts with cents only when they are small
+func formatMoney(amount float64) string {
+	if amount < 1000 {
+		return fmt.Sprintf("%.2f", amount)
+	}
+	return fmt.Sprintf("%.0f", amount)
+}
+
+// formatYieldEstimates renders the /yield ranking
+func formatYieldEstimates(token string, amount float64, estimates []YieldEstimate) string {
+	var message strings.Builder
+	message.WriteString(fmt.Sprintf("*Yield on %s %s* (APY)\n", formatAmount(amount), token))
+	message.WriteString("`   Source     APY    Daily  Monthly   Yearly`\n")
+	for i, estimate := range estimates {
+		message.WriteString(fmt.Sprintf("`%d. %-8s%5.1f%%%9s%9s%9s`",
+			i+1, estimate.Rate.Source, estimate.EffectiveAPY,
+			formatMoney(estimate.Daily), formatMoney(estimate.Monthly), formatMoney(estimate.Yearly)))
+		if len(estimate.Rate.Tiers) > 0 && estimate.Rate.LendingRate-estimate.EffectiveAPY >= 0.05 {
+			message.WriteString(fmt.Sprintf(" (headline %.1f%%)", estimate.Rate.LendingRate))
+		}
+		if estimate.Rate.Stale {
+			message.WriteString(" ⏳ " + formatAge(time.Since(estimate.Rate.FetchedAt)))
+		}
+		message.WriteString("\n")
+	}
+	return message.String()
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    float64
+		wantErr bool
+	}{
+		{"250000", 250000, false},
+		{"250,000", 250000, false},
+		{"250k", 250000, false},
+		{"1.5M", 1500000, false},
+		{"-5", 0, true},
+		{"lots", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseAmount(tt.input)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseAmount(%q) = %v, %v, want %v (error %v)", tt.input, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestEffectiveRate(t *testing.T) {
+	tiered := Rate{LendingRate: 25, Tiers: []RateTier{
+		{MaxAmount: 500, LendingRate: 25},
+		{MinAmount: 500, LendingRate: 5},
+	}}
+	capped := Rate{LendingRate: 10, Tiers: []RateTier{{MaxAmount: 1000, LendingRate: 10}}}
+
+	tests := []struct {
+		name   string
+		rate   Rate
+		amount float64
+		want   float64
+	}{
+		{"within first tier", tiered, 400, 25},
+		{"across tiers", tiered, 1000, 15},
+		{"large deposit", tiered, 100000, 5.1},
+		{"above capped tier", capped, 2000, 5},
+		{"flat rate", Rate{LendingRate: 8}, 100000, 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := effectiveRate(tt.rate, tt.amount); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("effectiveRate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEstimateYields(t *testing.T) {
+	rates := []Rate{
+		{Source: "Bybit", Token: "USDT", LendingRate: 25, Tiers: []RateTier{
+			{MaxAmount: 500, LendingRate: 25},
+			{MinAmount: 500, LendingRate: 2},
+		}},
+		{Source: "Neptune", Token: "USDT", LendingRate: 10},
+		{Source: "Neptune", Token: "USDC", LendingRate: 30},
+	}
+
+	estimates := estimateYields(rates, "USDT", 100000)
+	if len(estimates) != 2 || estimates[0].Rate.Source != "Neptune" || estimates[1].Rate.Source != "Bybit" {
+		t.Fatalf("estimateYields() = %+v, want Neptune ranked above Bybit", estimates)
+	}
+	if estimates[0].Yearly != 10000 {
+		t.Errorf("estimateYields() yearly = %v, want 10000", estimates[0].Yearly)
+	}
+	if daily := estimates[0].Daily; daily < 26 || daily > 27.4 {
+		t.Errorf("estimateYields() daily = %v, want about 26.1", daily)
+	}
+	if estimates[0].Monthly <= estimates[0].Daily*28 || estimates[0].Monthly >= estimates[0].Yearly/12+1 {
+		t.Errorf("estimateYields() monthly = %v, inconsistent with daily %v and yearly %v",
+			estimates[0].Monthly, estimates[0].Daily, estimates[0].Yearly)
+	}
+
+	message := formatYieldEstimates("USDT", 100000, estimates)
+	for _, want := range []string{"Yield on 100k USDT", "1. Neptune", "2. Bybit", "(headline 25.0%)"} {
+		if !strings.Contains(message, want) {
+			t.Errorf("formatYieldEstimates() missing %q in:\n%s", want, message)
+		}
+	}
+}